@@ -1,335 +1,220 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
-	"unicode"
+
+	"nand2tetris/06/assembler/hackasm"
 )
 
 var source = flag.String("s", "", "source file path")
 var target = flag.String("t", "", "output file path")
+var disassemble = flag.Bool("d", false, "disassemble the .hack source file into assembly")
+var symbolMapPath = flag.String("m", "", "symbol map file used to restore names when disassembling or profiling a .hack file")
+var emulate = flag.Bool("e", false, "run the .hack or .asm source file in the emulator and dump RAM")
+var maxCycles = flag.Int64("cycles", 10000000, "max cycles to run in the emulator, 0 means no limit")
+var breakpoints = flag.String("break", "", "comma separated ROM addresses to stop the emulator at")
+var dumpRanges = flag.String("dump", "0-15", "comma separated RAM ranges to dump after running, e.g. 0-15,256-300")
+var script = flag.Bool("tst", false, "run the .tst test script source file and compare with its .cmp file")
+var writeSym = flag.Bool("sym", false, "also write a .sym symbol map next to the output file")
+var writeLst = flag.Bool("lst", false, "also write a .lst listing next to the output file")
+var extended = flag.Bool("x", false, "accept the extended instruction set: shifts and commuted comp forms like A+D")
+var optimize = flag.Bool("O", false, "run the peephole optimizer before encoding and report instruction counts to stderr; jumps to @constant addresses are relabelled, computed jumps to constant addresses kept in D or RAM are not supported")
+var variableBase = flag.Int("varbase", hackasm.VariableBase, "RAM address of the first variable")
+var variableLimit = flag.Int("varlimit", hackasm.VariableLimit, "variables must stay below this RAM address, SCREEN by default")
+var compileObject = flag.Bool("c", false, "assemble the source file into a relocatable object instead of a .hack program")
+var link = flag.Bool("link", false, "link the object or .asm files given as arguments into one program")
+var lint = flag.Bool("lint", false, "check the source file for common mistakes without emitting code")
+var debug = flag.Bool("debug", false, "debug the .asm or .hack source file interactively")
+var screenPNG = flag.String("png", "", "with -e, write the final screen to this PNG file")
+var screenGIF = flag.String("gif", "", "with -e, record the screen into this animated GIF file")
+var gifEvery = flag.Int64("frame", 100000, "with -gif, cycles between two frames")
+var keyScript = flag.String("keys", "", "with -e or -debug, replay this keystroke script into KBD")
+var flatProfile = flag.String("prof", "", "with -e, write a flat profile of executed instructions per function and label to this file")
+var pprofProfile = flag.String("pprof", "", "with -e, write a gzipped pprof profile for go tool pprof to this file")
+var outputFormat = flag.String("f", "hack", "output format: hack, bin (big-endian words), ihex (Intel HEX), memh ($readmemh) or logisim")
 
 func main() {
 	flag.Parse()
+	var output bytes.Buffer
+	if *link {
+		if err := doLink(flag.Args(), &output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		writeOutput(output.Bytes())
+		return
+	}
 	reader, err := os.Open(*source)
 	if err != nil {
 		panic(err)
 	}
 	defer reader.Close()
-	writer, err := os.OpenFile(*target, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
-	if err != nil {
-		panic(err)
+	if *disassemble {
+		err = doDisassemble(reader, &output)
+	} else if *emulate {
+		err = doEmulate(&output)
+	} else if *script {
+		err = doScript(&output)
+	} else if *debug {
+		err = doDebug(reader)
+	} else if *lint {
+		err = doLint(reader)
+	} else if *compileObject {
+		err = doCompileObject(reader, &output)
+	} else {
+		err = doAssemble(reader, &output)
 	}
-	defer writer.Close()
-	DoParser(reader, writer)
-}
-
-func DoParser(reader io.Reader, writer io.Writer) {
-	data, err := ioutil.ReadAll(reader)
 	if err != nil {
-		panic(err)
-	}
-	symbolParser := NewParser(bytes.NewReader(data))
-	table := NewSymbolTalbe()
-	codeAddress := 0
-	for symbolParser.HasMoreCommands() {
-		symbolParser.Advance()
-		if symbolParser.CommandType() == L_COMMAND {
-			table.AddEntry(symbolParser.Symbol(), codeAddress)
-		} else if symbolParser.CommandType() == A_COMMAND || symbolParser.CommandType() == C_COMMAND {
-			codeAddress += 1
-		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	writeOutput(output.Bytes())
+}
 
-	// fmt.Println("---------------")
-
-	var codes []string
-	parser := NewParser(bytes.NewReader(data))
-	for parser.HasMoreCommands() {
-		parser.Advance()
-		// fmt.Printf("comand: %+v\n", parser.curCommand)
-		switch parser.CommandType() {
-		case L_COMMAND:
-			continue
-		case A_COMMAND:
-			symbol := parser.Symbol()
-			var aVal int64
-			if isNumber(symbol) {
-				aVal, _ = strconv.ParseInt(symbol, 10, 32)
-			} else {
-				if !table.Contains(symbol) {
-					table.AddVariable(symbol)
-				}
-				aVal = int64(table.GetAddress(symbol))
-			}
-			fmt.Printf("A: 0%015b\n", aVal)
-			codes = append(codes, fmt.Sprintf("0%015b", aVal))
-		case C_COMMAND:
-			code := "111"
-			comp := parser.Comp()
-			code += compInstructMap[comp]
-			dest := parser.Dest()
-			code += destRegMap[dest]
-			jump := parser.Jump()
-			fmt.Printf("comp: %s, dest: %s, jump: %s\n", comp, dest, jump)
-			code += jumpMap[string(jump)]
-			fmt.Printf("C: %s\n", code)
-			codes = append(codes, code)
-		}
+// writeOutput 将结果写到-t指定的文件，没有指定时写到标准输出
+func writeOutput(data []byte) {
+	if *target == "" {
+		os.Stdout.Write(data)
+		return
 	}
-
-	bufWriter := bufio.NewWriter(writer)
-	for _, code := range codes {
-		bufWriter.WriteString(code + "\n")
+	if err := ioutil.WriteFile(*target, data, 0666); err != nil {
+		panic(err)
 	}
-	bufWriter.Flush()
-}
-
-var jumpMap = map[string]string{
-	"null": "000",
-	"JGT":  "001",
-	"JEQ":  "010",
-	"JGE":  "011",
-	"JLT":  "100",
-	"JNE":  "101",
-	"JLE":  "110",
-	"JMP":  "111",
-}
-
-var destRegMap = map[string]string{
-	"null": "000",
-	"M":    "001",
-	"D":    "010",
-	"MD":   "011",
-	"A":    "100",
-	"AM":   "101",
-	"AD":   "110",
-	"AMD":  "111",
-}
-
-var compInstructMap = map[string]string{
-	"0":   "0101010",
-	"1":   "0111111",
-	"-1":  "0111010",
-	"D":   "0001100",
-	"A":   "0110000",
-	"M":   "1110000",
-	"!D":  "0001101",
-	"!A":  "0110001",
-	"!M":  "1110001",
-	"-D":  "0001111",
-	"-A":  "0110011",
-	"-M":  "1110011",
-	"D+1": "0011111",
-	"A+1": "0110111",
-	"M+1": "1110111",
-	"D-1": "0001110",
-	"A-1": "0110010",
-	"M-1": "1110010",
-	"D+A": "0000010",
-	"D+M": "1000010",
-	"D-A": "0010011",
-	"D-M": "1010011",
-	"A-D": "0000111",
-	"M-D": "1000111",
-	"D&A": "0000000",
-	"D&M": "1000000",
-	"D|A": "0010101",
-	"D|M": "1010101",
 }
 
-func isNumber(str string) bool {
-	for _, c := range str {
-		if !unicode.IsDigit(c) {
-			return false
+func doDisassemble(reader io.Reader, writer io.Writer) error {
+	symbols := hackasm.NewSymbolMap()
+	if *symbolMapPath != "" {
+		mapReader, err := os.Open(*symbolMapPath)
+		if err != nil {
+			return err
+		}
+		defer mapReader.Close()
+		symbols, err = hackasm.ReadSymbolMap(*symbolMapPath, mapReader)
+		if err != nil {
+			return err
 		}
 	}
-	return true
+	return hackasm.Disassemble(*source, reader, writer, symbols)
 }
 
-func NewParser(reader io.Reader) Parser {
-	return Parser{
-		reader: bufio.NewReader(reader),
+func assembleOptions() hackasm.Options {
+	return hackasm.Options{
+		Extended:      *extended,
+		Optimize:      *optimize,
+		VariableBase:  *variableBase,
+		VariableLimit: *variableLimit,
 	}
 }
 
-type Parser struct {
-	reader     *bufio.Reader
-	eof        bool
-	curLine    string
-	curCommand Command
-}
-
-type CommandType int32
-
-const (
-	A_COMMAND  CommandType = 1
-	C_COMMAND  CommandType = 2
-	L_COMMAND  CommandType = 3
-	EMPTY_LINE CommandType = 4
-	COMMENT    CommandType = 5
-)
-
-type JumpType string
-
-const (
-	Null JumpType = "null"
-	JGT           = "JGT"
-	JEQ           = "JEQ"
-	JGE           = "JGE"
-	JLT           = "JLT"
-	JNE           = "JNE"
-	JLE           = "JLE"
-	JMP           = "JMP"
-)
-
-type Command struct {
-	commandType CommandType
-	symbol      string
-	destType    string
-	comp        string
-	jump        JumpType
-}
-
-func (p *Parser) HasMoreCommands() bool {
-	line, _, err := p.reader.ReadLine()
+func doAssemble(reader io.Reader, writer io.Writer) error {
+	format, err := hackasm.ParseOutputFormat(*outputFormat)
 	if err != nil {
-		if err == io.EOF {
-			return false
-		}
-		panic(err)
+		return err
+	}
+	words, table, program, err := hackasm.AssembleFile(*source, reader, assembleOptions())
+	if err != nil {
+		return err
+	}
+	if program.Optimization != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *source, program.Optimization)
+	}
+	if err := hackasm.WriteWords(writer, words, format); err != nil {
+		return err
 	}
-	p.curLine = string(line)
-	return true
-}
 
-func (p *Parser) Advance() {
-	line := p.curLine
-	// fmt.Printf("raw: %s\n", line)
-	line = strings.TrimSpace(line)
-	curCommand := Command{}
-	if len(line) == 0 {
-		curCommand.commandType = EMPTY_LINE
-	} else if line[0] == '@' { // A指令
-		curCommand.commandType = A_COMMAND
-		curCommand.symbol = string(line[1:])
-	} else if line[0] == '(' { // L指令
-		curCommand.commandType = L_COMMAND
-		length := len(line)
-		for i, c := range line[1:] {
-			if c == ')' && i != length-2 {
-				panic(fmt.Errorf("expect end command, got %s", line[i+1:]))
-			}
-		}
-		curCommand.symbol = string(line[1 : length-1])
-	} else if string(line[0:2]) == "//" {
-		curCommand.commandType = COMMENT
-	} else { // C指令
-		curCommand.commandType = C_COMMAND
-		commentIndex := strings.Index(line, "//")
-		if commentIndex != -1 {
-			line = strings.TrimSpace(line[:commentIndex])
-		}
-		segments := strings.Split(line, ";")
-		if len(segments) > 2 {
-			panic(fmt.Errorf("invalid grammar for '%s'", line))
+	if *writeLst {
+		var lstBuf bytes.Buffer
+		if err := hackasm.WriteListing(&lstBuf, program.Listing(words)); err != nil {
+			return err
 		}
-		calCommand := segments[0]
-		calCommandSegments := strings.Split(calCommand, "=")
-		if len(calCommandSegments) > 2 {
-			panic(fmt.Errorf("invalid grammar for '%s'", line))
+		if err := ioutil.WriteFile(outputBase()+".lst", lstBuf.Bytes(), 0666); err != nil {
+			return err
 		}
-		var jump string
-		if len(segments) == 2 {
-			jump = segments[1]
-		} else {
-			jump = "null"
-		}
-
-		var destReg string
-		var comp string
-		if len(calCommandSegments) == 2 {
-			destReg = calCommandSegments[0]
-			comp = calCommandSegments[1]
-		} else {
-			destReg = "null"
-			comp = calCommandSegments[0]
-		}
-
-		curCommand.destType = destReg
-		curCommand.comp = comp
-		curCommand.jump = JumpType(jump)
 	}
-	p.curCommand = curCommand
+	return writeSymbols(table)
 }
 
-func (p *Parser) CommandType() CommandType {
-	return p.curCommand.commandType
-}
-
-func (p *Parser) Symbol() string {
-	return p.curCommand.symbol
+// outputBase 附加输出文件的路径前缀，与-t相同，没有-t时与-s相同
+func outputBase() string {
+	base := strings.TrimSuffix(*target, filepath.Ext(*target))
+	if base == "" {
+		base = strings.TrimSuffix(*source, filepath.Ext(*source))
+	}
+	return base
 }
 
-func (p *Parser) Dest() string {
-	return p.curCommand.destType
+// writeSymbols 指定-sym时输出符号表
+func writeSymbols(table *hackasm.SymbolTable) error {
+	if !*writeSym {
+		return nil
+	}
+	var symBuf bytes.Buffer
+	if err := hackasm.WriteSymbols(&symBuf, table); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outputBase()+".sym", symBuf.Bytes(), 0666)
 }
 
-func (p *Parser) Comp() string {
-	return p.curCommand.comp
+// doLint 只检查源码，有警告时以错误返回
+func doLint(reader io.Reader) error {
+	program, err := hackasm.Parse(*source, reader)
+	if err != nil {
+		return err
+	}
+	return hackasm.Lint(program)
 }
 
-func (p *Parser) Jump() JumpType {
-	return p.curCommand.jump
+func doCompileObject(reader io.Reader, writer io.Writer) error {
+	object, err := hackasm.AssembleObject(*source, reader, assembleOptions())
+	if err != nil {
+		return err
+	}
+	return hackasm.WriteObject(writer, object)
 }
 
-func NewSymbolTalbe() SymbolTable {
-	initTable := map[string]int{
-		"SP":     0,
-		"LCL":    1,
-		"ARG":    2,
-		"THIS":   3,
-		"THAT":   4,
-		"SCREEN": 16384,
-		"KBD":    24576,
+// doLink 链接目标文件，.asm文件会先汇编为目标文件
+func doLink(paths []string, writer io.Writer) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no object files to link")
 	}
-	for i := 0; i <= 15; i++ {
-		initTable[fmt.Sprintf("R%d", i)] = i
+	format, err := hackasm.ParseOutputFormat(*outputFormat)
+	if err != nil {
+		return err
 	}
-
-	return SymbolTable{
-		table:           initTable,
-		variableAddress: 16,
+	objects := make([]*hackasm.Object, 0, len(paths))
+	for _, path := range paths {
+		object, err := loadObject(path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, object)
 	}
+	words, table, err := hackasm.Link(objects, assembleOptions())
+	if err != nil {
+		return err
+	}
+	if err := hackasm.WriteWords(writer, words, format); err != nil {
+		return err
+	}
+	return writeSymbols(table)
 }
 
-type SymbolTable struct {
-	table           map[string]int
-	variableAddress int
-}
-
-func (s *SymbolTable) AddEntry(symbol string, address int) {
-	s.table[symbol] = address
-}
-
-func (s *SymbolTable) AddVariable(symbol string) {
-	s.table[symbol] = s.variableAddress
-	s.variableAddress += 1
-}
-
-func (s *SymbolTable) Contains(symbol string) bool {
-	_, ok := s.table[symbol]
-	return ok
-}
-
-func (s *SymbolTable) GetAddress(symbol string) int {
-	address := s.table[symbol]
-	return address
+func loadObject(path string) (*hackasm.Object, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".asm") {
+		return hackasm.AssembleObject(path, f, assembleOptions())
+	}
+	return hackasm.ReadObject(path, f)
 }
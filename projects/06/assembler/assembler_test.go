@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nand2tetris/06/assembler/hackasm"
+)
+
+// TestLinkFiles 链接一个.asm文件和一个由-c生成的目标文件
+func TestLinkFiles(t *testing.T) {
+	dir := t.TempDir()
+	mainPath, mathPath := filepath.Join(dir, "main.asm"), filepath.Join(dir, "math.o")
+	if err := os.WriteFile(mainPath, []byte("#import ADD\n@ADD\n0;JMP"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	object, err := hackasm.AssembleObject("math.asm", strings.NewReader("#export ADD\n(ADD)\n@ADD\n0;JMP"), hackasm.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objectText bytes.Buffer
+	if err := hackasm.WriteObject(&objectText, object); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mathPath, objectText.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	if err := doLink([]string{mainPath, mathPath}, &output); err != nil {
+		t.Fatalf("link err: %v", err)
+	}
+	expect := "0000000000000010\n1110101010000111\n0000000000000010\n1110101010000111\n"
+	if output.String() != expect {
+		t.Errorf("expect\n%s\ngot\n%s", expect, output.String())
+	}
+
+	// 缺少导出ADD的目标文件
+	err = doLink([]string{mainPath}, &output)
+	if err == nil || !strings.Contains(err.Error(), "main.asm:2:1: unresolved symbol: 'ADD'") {
+		t.Errorf("expect an unresolved ADD, got %v", err)
+	}
+	if err := doLink(nil, &output); err == nil {
+		t.Errorf("expect an error without files")
+	}
+}
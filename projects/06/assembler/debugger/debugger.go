@@ -0,0 +1,466 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nand2tetris/06/assembler/emulator"
+	"nand2tetris/06/assembler/hackasm"
+)
+
+const help = `commands:
+  step, s [n]          execute n instructions (default 1)
+  next, n              like step, but runs over an unconditional jump until it returns to the next instruction
+  continue, c          run until a breakpoint, a watchpoint, the end loop or the cycle limit
+  break, b LOC         set a breakpoint at a label or ROM address
+  delete, d [LOC]      delete the breakpoint at LOC, or all breakpoints
+  watch, w CELL        stop when RAM at a symbol or address changes, e.g. watch SP, watch RAM[256]
+  unwatch CELL         delete a watchpoint
+  print, p EXPR        print A, D, PC, a symbol, RAM[n] or a label address
+  info, i              show registers, breakpoints and watchpoints
+  stack                show SP, LCL, ARG, THIS, THAT and RAM[LCL..SP-1]
+  x FROM[-TO]          dump RAM
+  set CELL VALUE       write RAM at a symbol or address, or the register A, D or PC
+  list, l              show source around PC
+  reset                set PC to 0 and clear the cycle counter
+  quit, q              leave the debugger
+an empty line repeats the last step, next or continue`
+
+// Debugger 源码级的Hack程序调试器，断点和观察点可以使用汇编时的符号
+type Debugger struct {
+	// MaxCycles continue和next最多执行的指令数，<=0表示不限制
+	MaxCycles int64
+
+	computer    *emulator.Computer
+	table       *hackasm.SymbolTable
+	source      []*hackasm.Instruction
+	labels      map[uint16][]string
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]bool
+	out         io.Writer
+	last        string
+}
+
+// New 调试words，program和table是汇编时得到的程序与符号表，调试.hack文件时可以为nil
+func New(words []uint16, program *hackasm.Program, table *hackasm.SymbolTable) (*Debugger, error) {
+	computer, err := emulator.NewComputer(words)
+	if err != nil {
+		return nil, err
+	}
+	d := &Debugger{
+		MaxCycles:   10000000,
+		computer:    computer,
+		table:       table,
+		source:      make([]*hackasm.Instruction, len(words)),
+		labels:      map[uint16][]string{},
+		breakpoints: map[uint16]bool{},
+		watchpoints: map[uint16]bool{},
+		out:         ioutil.Discard,
+	}
+	if program != nil {
+		address := 0
+		for i := range program.Instructions {
+			ins := &program.Instructions[i]
+			if ins.Type == hackasm.L_COMMAND {
+				d.labels[uint16(address)] = append(d.labels[uint16(address)], ins.Symbol)
+				continue
+			}
+			if address < len(d.source) {
+				d.source[address] = ins
+			}
+			address += 1
+		}
+	}
+	return d, nil
+}
+
+// Computer 被调试的计算机
+func (d *Debugger) Computer() *emulator.Computer {
+	return d.computer
+}
+
+// Run 从in读取命令并执行，直到quit或者in结束
+func (d *Debugger) Run(in io.Reader, out io.Writer) error {
+	d.out = out
+	d.showPosition()
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "(hdb) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		quit, err := d.Exec(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// Exec 执行一条命令，quit为true表示退出调试器
+func (d *Debugger) Exec(line string) (quit bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		if d.last == "" {
+			return false, nil
+		}
+		fields = strings.Fields(d.last)
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "step", "s":
+		d.last = line
+		n := 1
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+				return false, fmt.Errorf("invalid step count '%s'", args[0])
+			}
+		}
+		return false, d.step(n)
+	case "next", "n":
+		d.last = line
+		return false, d.next()
+	case "continue", "c":
+		d.last = line
+		return false, d.cont()
+	case "break", "b":
+		if len(args) != 1 {
+			return false, fmt.Errorf("expect break LABEL|ADDRESS")
+		}
+		address, err := d.romAddress(args[0])
+		if err != nil {
+			return false, err
+		}
+		d.breakpoints[address] = true
+		d.computer.SetBreakpoint(address)
+		fmt.Fprintf(d.out, "breakpoint at %s\n", d.describeROM(address))
+	case "delete", "d":
+		if len(args) == 0 {
+			d.breakpoints = map[uint16]bool{}
+			d.computer.ClearBreakpoints()
+			return false, nil
+		}
+		address, err := d.romAddress(args[0])
+		if err != nil {
+			return false, err
+		}
+		delete(d.breakpoints, address)
+		d.computer.ClearBreakpoint(address)
+	case "watch", "w", "unwatch":
+		if len(args) != 1 {
+			return false, fmt.Errorf("expect %s SYMBOL|ADDRESS|RAM[n]", fields[0])
+		}
+		address, err := d.ramAddress(args[0])
+		if err != nil {
+			return false, err
+		}
+		if fields[0] == "unwatch" {
+			delete(d.watchpoints, address)
+			d.computer.ClearWatchpoint(address)
+			return false, nil
+		}
+		d.watchpoints[address] = true
+		d.computer.SetWatchpoint(address)
+		fmt.Fprintf(d.out, "watchpoint on %s = %d\n", d.describeRAM(address), int16(d.computer.RAM[address]))
+	case "print", "p":
+		if len(args) != 1 {
+			return false, fmt.Errorf("expect print EXPR")
+		}
+		return false, d.print(args[0])
+	case "info", "i":
+		d.info()
+	case "stack":
+		d.stack()
+	case "x":
+		if len(args) != 1 {
+			return false, fmt.Errorf("expect x FROM[-TO]")
+		}
+		from, to, err := d.ramRange(args[0])
+		if err != nil {
+			return false, err
+		}
+		return false, d.computer.DumpRAM(d.out, from, to)
+	case "set":
+		if len(args) != 2 {
+			return false, fmt.Errorf("expect set CELL VALUE")
+		}
+		return false, d.set(args[0], args[1])
+	case "list", "l":
+		d.list()
+	case "reset":
+		d.computer.Reset()
+		d.showPosition()
+	case "help", "h":
+		fmt.Fprintln(d.out, help)
+	case "quit", "q":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command '%s', try help", fields[0])
+	}
+	return false, nil
+}
+
+func (d *Debugger) step(n int) error {
+	for i := 0; i < n; i++ {
+		if err := d.computer.Step(); err != nil {
+			return err
+		}
+		if watch, ok := d.computer.Watched(); ok {
+			d.showWatch(watch)
+			break
+		}
+		if d.computer.Halted() {
+			fmt.Fprintln(d.out, "halted in infinite loop")
+			break
+		}
+	}
+	d.showPosition()
+	return nil
+}
+
+// next 无条件跳转视为调用，执行到跳转的下一条指令，即CodeWriter中call之后的返回地址
+func (d *Debugger) next() error {
+	pc := d.computer.PC
+	if int(pc) >= len(d.source) || !isUnconditionalJump(d.computer.ROM[pc]) {
+		return d.step(1)
+	}
+	returnAddress := pc + 1
+	temporary := !d.breakpoints[returnAddress]
+	d.computer.SetBreakpoint(returnAddress)
+	err := d.cont()
+	if temporary {
+		d.computer.ClearBreakpoint(returnAddress)
+	}
+	return err
+}
+
+func isUnconditionalJump(word uint16) bool {
+	return word&0x8000 != 0 && word&0x7 == 0x7
+}
+
+func (d *Debugger) cont() error {
+	reason, err := d.computer.Run(d.MaxCycles)
+	if err != nil {
+		d.showPosition()
+		return err
+	}
+	switch reason {
+	case emulator.StopWatchpoint:
+		watch, _ := d.computer.Watched()
+		d.showWatch(watch)
+	case emulator.StopBreakpoint:
+		fmt.Fprintf(d.out, "breakpoint at %s\n", d.describeROM(d.computer.PC))
+	default:
+		fmt.Fprintln(d.out, reason)
+	}
+	d.showPosition()
+	return nil
+}
+
+func (d *Debugger) showWatch(watch emulator.Watch) {
+	fmt.Fprintf(d.out, "watchpoint %s: %d -> %d\n", d.describeRAM(watch.Address), int16(watch.Old), int16(watch.New))
+}
+
+// showPosition 显示寄存器以及PC处的源码
+func (d *Debugger) showPosition() {
+	c := d.computer
+	fmt.Fprintf(d.out, "PC=%d A=%d D=%d cycles=%d\n", c.PC, int16(c.A), int16(c.D), c.Cycles)
+	fmt.Fprintf(d.out, "=> %s\n", d.sourceLine(c.PC))
+}
+
+// sourceLine ROM地址对应的源码，没有源码时显示机器码，跳出ROM时只显示地址
+func (d *Debugger) sourceLine(address uint16) string {
+	if int(address) >= emulator.ROMSize {
+		return fmt.Sprintf("%5d  PC outside ROM", address)
+	}
+	if int(address) < len(d.source) && d.source[address] != nil {
+		ins := d.source[address]
+		return fmt.Sprintf("%5d  %s  %s", address, ins.Pos, strings.TrimSpace(ins.Source))
+	}
+	return fmt.Sprintf("%5d  %016b", address, d.computer.ROM[address])
+}
+
+func (d *Debugger) list() {
+	pc := int(d.computer.PC)
+	for address := pc - 3; address <= pc+3; address++ {
+		if address < 0 || address >= d.computer.ProgramSize() {
+			continue
+		}
+		for _, label := range d.labels[uint16(address)] {
+			fmt.Fprintf(d.out, "       (%s)\n", label)
+		}
+		marker := "  "
+		if address == pc {
+			marker = "=>"
+		}
+		if d.breakpoints[uint16(address)] {
+			marker = marker[:1] + "*"
+		}
+		fmt.Fprintf(d.out, "%s %s\n", marker, d.sourceLine(uint16(address)))
+	}
+}
+
+func (d *Debugger) info() {
+	c := d.computer
+	fmt.Fprintf(d.out, "A=%d D=%d PC=%d cycles=%d\n", int16(c.A), int16(c.D), c.PC, c.Cycles)
+	for _, address := range sortedAddresses(d.breakpoints) {
+		fmt.Fprintf(d.out, "breakpoint %s\n", d.describeROM(address))
+	}
+	for _, address := range sortedAddresses(d.watchpoints) {
+		fmt.Fprintf(d.out, "watchpoint %s = %d\n", d.describeRAM(address), int16(c.RAM[address]))
+	}
+}
+
+// stackWindow stack最多显示的栈元素个数
+const stackWindow = 64
+
+func (d *Debugger) stack() {
+	ram := d.computer.RAM[:]
+	for i, name := range []string{"SP", "LCL", "ARG", "THIS", "THAT"} {
+		fmt.Fprintf(d.out, "%-4s = %d\n", name, int16(ram[i]))
+	}
+	lcl, sp := int(ram[1]), int(ram[0])
+	if lcl > sp || sp >= emulator.ScreenAddress {
+		fmt.Fprintf(d.out, "no frame, LCL %d is above SP %d\n", lcl, sp)
+		return
+	}
+	if sp-lcl > stackWindow {
+		fmt.Fprintf(d.out, "... %d more\n", sp-lcl-stackWindow)
+		lcl = sp - stackWindow
+	}
+	for address := lcl; address < sp; address++ {
+		fmt.Fprintf(d.out, "RAM[%d] = %d\n", address, int16(ram[address]))
+	}
+}
+
+func (d *Debugger) print(expr string) error {
+	c := d.computer
+	switch expr {
+	case "A":
+		fmt.Fprintf(d.out, "A = %d\n", int16(c.A))
+		return nil
+	case "D":
+		fmt.Fprintf(d.out, "D = %d\n", int16(c.D))
+		return nil
+	case "PC":
+		fmt.Fprintf(d.out, "PC = %d\n", c.PC)
+		return nil
+	}
+	if d.table != nil && d.table.Kind(expr) == hackasm.LABEL {
+		fmt.Fprintf(d.out, "%s = ROM[%d]\n", expr, d.table.GetAddress(expr))
+		return nil
+	}
+	address, err := d.ramAddress(expr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(d.out, "%s = %d\n", d.describeRAM(address), int16(c.RAM[address]))
+	return nil
+}
+
+func (d *Debugger) set(cell, text string) error {
+	value, err := strconv.ParseInt(text, 10, 32)
+	if err != nil || value < -32768 || value > 65535 {
+		return fmt.Errorf("invalid value '%s'", text)
+	}
+	c := d.computer
+	switch cell {
+	case "A":
+		c.A = uint16(value)
+	case "D":
+		c.D = uint16(value)
+	case "PC":
+		c.PC = uint16(value)
+	default:
+		address, err := d.ramAddress(cell)
+		if err != nil {
+			return err
+		}
+		c.RAM[address] = uint16(value)
+	}
+	return nil
+}
+
+// romAddress 解析标签或者ROM地址
+func (d *Debugger) romAddress(loc string) (uint16, error) {
+	if d.table != nil && d.table.Kind(loc) == hackasm.LABEL {
+		return uint16(d.table.GetAddress(loc)), nil
+	}
+	loc = strings.TrimSuffix(strings.TrimPrefix(loc, "ROM["), "]")
+	address, err := strconv.ParseUint(loc, 10, 16)
+	if err != nil || address >= emulator.ROMSize {
+		return 0, fmt.Errorf("unknown label or ROM address '%s'", loc)
+	}
+	return uint16(address), nil
+}
+
+// ramAddress 解析变量、预定义符号、RAM[n]或者数字表示的RAM地址
+func (d *Debugger) ramAddress(cell string) (uint16, error) {
+	if d.table != nil && d.table.Contains(cell) && d.table.Kind(cell) != hackasm.LABEL {
+		return uint16(d.table.GetAddress(cell)), nil
+	}
+	cell = strings.TrimSuffix(strings.TrimPrefix(cell, "RAM["), "]")
+	address, err := strconv.ParseUint(cell, 10, 16)
+	if err != nil || address >= emulator.RAMSize {
+		return 0, fmt.Errorf("unknown symbol or RAM address '%s'", cell)
+	}
+	return uint16(address), nil
+}
+
+func (d *Debugger) ramRange(r string) (int, int, error) {
+	bounds := strings.SplitN(r, "-", 2)
+	from, err := d.ramAddress(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	to := from
+	if len(bounds) == 2 {
+		if to, err = d.ramAddress(bounds[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return int(from), int(to), nil
+}
+
+func (d *Debugger) describeROM(address uint16) string {
+	if labels := d.labels[address]; len(labels) > 0 {
+		return fmt.Sprintf("%d (%s)", address, strings.Join(labels, ", "))
+	}
+	return strconv.Itoa(int(address))
+}
+
+// describeRAM 以 RAM[n] (符号) 的形式显示地址，R0..R15与SP等同名时取第一个
+func (d *Debugger) describeRAM(address uint16) string {
+	if d.table == nil {
+		return fmt.Sprintf("RAM[%d]", address)
+	}
+	var names []string
+	for _, name := range d.table.Symbols() {
+		if d.table.Kind(name) != hackasm.LABEL && d.table.GetAddress(name) == int(address) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("RAM[%d]", address)
+	}
+	return fmt.Sprintf("RAM[%d] (%s)", address, strings.Join(names, ", "))
+}
+
+func sortedAddresses(set map[uint16]bool) []uint16 {
+	addresses := make([]uint16, 0, len(set))
+	for address := range set {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+	return addresses
+}
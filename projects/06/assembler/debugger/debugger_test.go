@@ -0,0 +1,81 @@
+package debugger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"nand2tetris/06/assembler/hackasm"
+)
+
+func newDebugger(t *testing.T, path string) *Debugger {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s err: %v", path, err)
+	}
+	defer f.Close()
+	words, table, program, err := hackasm.AssembleFile(path, f, hackasm.Options{})
+	if err != nil {
+		t.Fatalf("assemble %s err: %v", path, err)
+	}
+	d, err := New(words, program, table)
+	if err != nil {
+		t.Fatalf("new debugger err: %v", err)
+	}
+	return d
+}
+
+func TestSession(t *testing.T) {
+	d := newDebugger(t, "../../max/Max.asm")
+	script := strings.Join([]string{
+		"set R0 3",
+		"set R1 9",
+		"break OUTPUT_D",
+		"continue",
+		"watch R2",
+		"c",
+		"p R2",
+		"bogus",
+		"q",
+	}, "\n")
+	var out bytes.Buffer
+	if err := d.Run(strings.NewReader(script), &out); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	for _, expect := range []string{
+		"breakpoint at 12 (OUTPUT_D)\nPC=12",
+		"watchpoint RAM[2] (ARG, R2): 0 -> 9",
+		"RAM[2] (ARG, R2) = 9",
+		"error: unknown command 'bogus'",
+	} {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("expect output to contain %q, got:\n%s", expect, out.String())
+		}
+	}
+	if d.Computer().PC != 14 {
+		t.Errorf("expect to stop after writing R2 at PC 14, got %d", d.Computer().PC)
+	}
+}
+
+// TestPCOutsideROM 跳出ROM后显示位置不能越界
+func TestPCOutsideROM(t *testing.T) {
+	source := "@32767\nD=A\nA=D+1\n0;JMP"
+	words, table, program, err := hackasm.AssembleFile("", strings.NewReader(source), hackasm.Options{})
+	if err != nil {
+		t.Fatalf("assemble err: %v", err)
+	}
+	d, err := New(words, program, table)
+	if err != nil {
+		t.Fatalf("new debugger err: %v", err)
+	}
+	var out bytes.Buffer
+	if err := d.Run(strings.NewReader("c\nq"), &out); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	for _, expect := range []string{"PC 32768 out of ROM", "32768  PC outside ROM"} {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("expect output to contain %q, got:\n%s", expect, out.String())
+		}
+	}
+}
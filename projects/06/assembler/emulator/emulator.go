@@ -0,0 +1,337 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	ROMSize         = 32768
+	ScreenAddress   = 16384
+	ScreenSize      = 8192
+	KeyboardAddress = 24576
+	// RAMSize 与Java的CPU模拟器一致，KBD之后的地址当作普通内存
+	RAMSize = 32768
+)
+
+// StopReason Run停止执行的原因
+type StopReason int
+
+const (
+	StopCycleLimit StopReason = iota
+	StopHalted
+	StopBreakpoint
+	StopWatchpoint
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopCycleLimit:
+		return "cycle limit reached"
+	case StopHalted:
+		return "halted in infinite loop"
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopWatchpoint:
+		return "watchpoint"
+	}
+	return fmt.Sprintf("StopReason(%d)", int(r))
+}
+
+// Computer 无界面的Hack计算机，包括32K的ROM、A/D/PC寄存器以及
+// 16K数据内存、8K屏幕内存（SCREEN）和键盘寄存器（KBD）组成的RAM
+type Computer struct {
+	ROM [ROMSize]uint16
+	RAM [RAMSize]uint16
+	A   uint16
+	D   uint16
+	PC  uint16
+
+	// Cycles 自上次Reset以来执行的指令数
+	Cycles int64
+	// Trace 不为nil时，每条指令执行之后以该指令的地址调用，用于性能分析等
+	Trace func(pc uint16)
+
+	programSize int
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]bool
+	halted      bool
+	watched     bool
+	lastWatch   Watch
+	keys        *KeyScript
+	nextKey     int
+}
+
+// Watch 观察点被触发时写入的地址以及写入前后的值
+type Watch struct {
+	Address uint16
+	Old     uint16
+	New     uint16
+}
+
+func NewComputer(program []uint16) (*Computer, error) {
+	c := &Computer{
+		breakpoints: map[uint16]bool{},
+		watchpoints: map[uint16]bool{},
+	}
+	if err := c.Load(program); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load 将程序写入ROM，并重置CPU
+func (c *Computer) Load(program []uint16) error {
+	if len(program) > ROMSize {
+		return fmt.Errorf("program has %d instructions, exceeds ROM size %d", len(program), ROMSize)
+	}
+	c.ROM = [ROMSize]uint16{}
+	copy(c.ROM[:], program)
+	c.programSize = len(program)
+	c.Reset()
+	return nil
+}
+
+// Reset 相当于按下reset：PC归零，寄存器和RAM保持不变，按键脚本从头重放
+func (c *Computer) Reset() {
+	c.PC = 0
+	c.Cycles = 0
+	c.halted = false
+	c.nextKey = 0
+}
+
+// ProgramSize 加载的程序的指令条数
+func (c *Computer) ProgramSize() int {
+	return c.programSize
+}
+
+// Halted 最后一次执行的指令是否是跳回自身的死循环，如 (END) @END 0;JMP
+func (c *Computer) Halted() bool {
+	return c.halted
+}
+
+func (c *Computer) SetBreakpoint(address uint16) {
+	c.breakpoints[address] = true
+}
+
+func (c *Computer) ClearBreakpoint(address uint16) {
+	delete(c.breakpoints, address)
+}
+
+func (c *Computer) ClearBreakpoints() {
+	c.breakpoints = map[uint16]bool{}
+}
+
+// SetWatchpoint 在address的值被改变后停止Run
+func (c *Computer) SetWatchpoint(address uint16) {
+	c.watchpoints[address] = true
+}
+
+func (c *Computer) ClearWatchpoint(address uint16) {
+	delete(c.watchpoints, address)
+}
+
+// Watched 最后一次执行的指令是否改变了观察点的值，是则返回对应的写入
+func (c *Computer) Watched() (Watch, bool) {
+	return c.lastWatch, c.watched
+}
+
+// Step 执行PC处的一条指令
+func (c *Computer) Step() error {
+	if int(c.PC) >= ROMSize {
+		return fmt.Errorf("PC %d out of ROM", c.PC)
+	}
+	if c.keys != nil {
+		c.pressKeys()
+	}
+	pc := c.PC
+	instruction := c.ROM[pc]
+	c.halted = false
+	c.watched = false
+	if instruction&0x8000 == 0 {
+		c.A = instruction
+		c.PC++
+		c.Cycles++
+		if c.Trace != nil {
+			c.Trace(pc)
+		}
+		return nil
+	}
+	isShift := instruction&0xe000 == 0xa000
+	if instruction&0xe000 != 0xe000 && !isShift {
+		return fmt.Errorf("invalid instruction %016b at ROM[%d]", instruction, pc)
+	}
+
+	a := c.A
+	var y uint16
+	if instruction&0x1000 != 0 {
+		if int(a) >= RAMSize {
+			return fmt.Errorf("read RAM[%d] out of range at ROM[%d]", a, pc)
+		}
+		y = c.RAM[a]
+	} else {
+		y = a
+	}
+	var out uint16
+	if isShift {
+		var ok bool
+		out, ok = shift(c.D, y, (instruction>>6)&0x3f)
+		if !ok {
+			return fmt.Errorf("invalid shift instruction %016b at ROM[%d]", instruction, pc)
+		}
+	} else {
+		out = alu(c.D, y, (instruction>>6)&0x3f)
+	}
+
+	if instruction&0x8 != 0 {
+		if int(a) >= RAMSize {
+			return fmt.Errorf("write RAM[%d] out of range at ROM[%d]", a, pc)
+		}
+		if c.watchpoints[a] && c.RAM[a] != out {
+			c.watched = true
+			c.lastWatch = Watch{Address: a, Old: c.RAM[a], New: out}
+		}
+		c.RAM[a] = out
+	}
+	if instruction&0x20 != 0 {
+		c.A = out
+	}
+	if instruction&0x10 != 0 {
+		c.D = out
+	}
+
+	if jump(int16(out), instruction&0x7) {
+		c.PC = a
+		c.halted = c.isSelfLoop(pc, a)
+	} else {
+		c.PC++
+	}
+	c.Cycles++
+	if c.Trace != nil {
+		c.Trace(pc)
+	}
+	return nil
+}
+
+// isSelfLoop 从pc跳到target后是否会永远停留在原地，
+// 即pc处是不写任何寄存器的无条件跳转，且跳回自身或者跳回加载自身地址的A指令
+func (c *Computer) isSelfLoop(pc, target uint16) bool {
+	if c.ROM[pc]&0x3f != 0x7 {
+		return false
+	}
+	return target == pc || (target+1 == pc && c.ROM[target] == target)
+}
+
+// Run 执行指令直到达到maxCycles（<=0表示不限制）、程序进入死循环、遇到断点或者观察点的值被改变。
+// 断点在对应地址的指令执行之前触发，Run的第一条指令不检查断点，以便从断点处继续执行
+func (c *Computer) Run(maxCycles int64) (StopReason, error) {
+	return c.run(maxCycles, nil)
+}
+
+// run 即Run，每执行一条指令后以已执行的指令数调用afterStep（可以为nil）
+func (c *Computer) run(maxCycles int64, afterStep func(executed int64)) (StopReason, error) {
+	for i := int64(0); maxCycles <= 0 || i < maxCycles; i++ {
+		if i > 0 && c.breakpoints[c.PC] {
+			return StopBreakpoint, nil
+		}
+		if err := c.Step(); err != nil {
+			return StopCycleLimit, err
+		}
+		if c.watched {
+			return StopWatchpoint, nil
+		}
+		if c.halted {
+			return StopHalted, nil
+		}
+		if afterStep != nil {
+			afterStep(i + 1)
+		}
+	}
+	return StopCycleLimit, nil
+}
+
+// DumpRAM 以有符号十进制输出RAM[from..to]
+func (c *Computer) DumpRAM(writer io.Writer, from, to int) error {
+	if from < 0 || to >= RAMSize || from > to {
+		return fmt.Errorf("invalid RAM range %d-%d", from, to)
+	}
+	bufWriter := bufio.NewWriter(writer)
+	for i := from; i <= to; i++ {
+		bufWriter.WriteString(fmt.Sprintf("RAM[%d] = %d\n", i, int16(c.RAM[i])))
+	}
+	return bufWriter.Flush()
+}
+
+func alu(x, y uint16, control uint16) uint16 {
+	if control&0x20 != 0 { // zx
+		x = 0
+	}
+	if control&0x10 != 0 { // nx
+		x = ^x
+	}
+	if control&0x8 != 0 { // zy
+		y = 0
+	}
+	if control&0x4 != 0 { // ny
+		y = ^y
+	}
+	var out uint16
+	if control&0x2 != 0 { // f
+		out = x + y
+	} else {
+		out = x & y
+	}
+	if control&0x1 != 0 { // no
+		out = ^out
+	}
+	return out
+}
+
+// shift 扩展指令集（前缀101）的移位运算：<<为逻辑左移一位，>>为算术右移一位
+func shift(x, y uint16, control uint16) (uint16, bool) {
+	switch control {
+	case 0x30: // D<<
+		return x << 1, true
+	case 0x20: // A<< M<<
+		return y << 1, true
+	case 0x10: // D>>
+		return uint16(int16(x) >> 1), true
+	case 0x00: // A>> M>>
+		return uint16(int16(y) >> 1), true
+	}
+	return 0, false
+}
+
+func jump(out int16, bits uint16) bool {
+	return (bits&0x4 != 0 && out < 0) ||
+		(bits&0x2 != 0 && out == 0) ||
+		(bits&0x1 != 0 && out > 0)
+}
+
+// LoadHack 读取.hack文件，每行一个16位的二进制机器码
+func LoadHack(reader io.Reader) ([]uint16, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var program []uint16
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) != 16 {
+			return nil, fmt.Errorf("line %d: expect 16 binary digits, got '%s'", i+1, line)
+		}
+		word, err := strconv.ParseUint(line, 2, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: expect 16 binary digits, got '%s'", i+1, line)
+		}
+		program = append(program, uint16(word))
+	}
+	return program, nil
+}
@@ -0,0 +1,96 @@
+package emulator
+
+import (
+	"os"
+	"testing"
+)
+
+func loadComputer(t *testing.T, path string) *Computer {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s err: %v", path, err)
+	}
+	defer f.Close()
+	program, err := LoadHack(f)
+	if err != nil {
+		t.Fatalf("load %s err: %v", path, err)
+	}
+	c, err := NewComputer(program)
+	if err != nil {
+		t.Fatalf("new computer err: %v", err)
+	}
+	return c
+}
+
+func TestMax(t *testing.T) {
+	c := loadComputer(t, "../../../05/Max.hack")
+	for _, tc := range [][3]int16{{3, 7, 7}, {7, 3, 7}, {-5, -9, -5}, {4, 4, 4}} {
+		c.Reset()
+		c.RAM[0], c.RAM[1] = uint16(tc[0]), uint16(tc[1])
+		reason, err := c.Run(1000)
+		if err != nil {
+			t.Fatalf("run err: %v", err)
+		}
+		if reason != StopHalted {
+			t.Fatalf("expect halted, got %s", reason)
+		}
+		if got := int16(c.RAM[2]); got != tc[2] {
+			t.Errorf("max(%d, %d): expect %d, got %d", tc[0], tc[1], tc[2], got)
+		}
+	}
+}
+
+func TestRect(t *testing.T) {
+	c := loadComputer(t, "../../../05/Rect.hack")
+	c.RAM[0] = 4
+	if _, err := c.Run(0); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	for row := 0; row < 5; row++ {
+		expect := uint16(0xffff)
+		if row == 4 {
+			expect = 0
+		}
+		if got := c.RAM[ScreenAddress+row*32]; got != expect {
+			t.Errorf("screen row %d: expect %016b, got %016b", row, expect, got)
+		}
+	}
+}
+
+func TestBreakpoint(t *testing.T) {
+	c := loadComputer(t, "../../../05/Max.hack")
+	c.SetBreakpoint(12)
+	reason, err := c.Run(1000)
+	if err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	if reason != StopBreakpoint || c.PC != 12 {
+		t.Fatalf("expect breakpoint at 12, got %s at %d", reason, c.PC)
+	}
+	reason, _ = c.Run(1000)
+	if reason != StopHalted {
+		t.Fatalf("expect halted after continue, got %s", reason)
+	}
+}
+
+func TestShift(t *testing.T) {
+	program := []uint16{
+		0x0005,             // @5
+		0b1110110000010000, // D=A
+		0b1010110000010000, // D=D<<
+		0x0000,             // @0
+		0b1110001100001000, // M=D
+		0b1011000000001000, // M=M>>
+		0b1011000000010000, // D=M>>
+	}
+	c, err := NewComputer(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run(int64(len(program))); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	if c.RAM[0] != 5 || c.D != 2 {
+		t.Errorf("expect RAM[0]=5 D=2, got RAM[0]=%d D=%d", c.RAM[0], c.D)
+	}
+}
@@ -0,0 +1,143 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// keyNames Hack键盘的特殊按键，与Java的CPU模拟器一致
+var keyNames = map[string]uint16{
+	"RELEASE":   0,
+	"SPACE":     32,
+	"NEWLINE":   128,
+	"ENTER":     128,
+	"BACKSPACE": 129,
+	"LEFT":      130,
+	"UP":        131,
+	"RIGHT":     132,
+	"DOWN":      133,
+	"HOME":      134,
+	"END":       135,
+	"PAGEUP":    136,
+	"PAGEDOWN":  137,
+	"INSERT":    138,
+	"DELETE":    139,
+	"ESC":       140,
+}
+
+func init() {
+	for i := 1; i <= 12; i++ {
+		keyNames[fmt.Sprintf("F%d", i)] = uint16(140 + i)
+	}
+}
+
+// KeyEvent 在Cycle条指令执行之后，将KBD设为Key
+type KeyEvent struct {
+	Cycle int64
+	Key   uint16
+}
+
+// KeyScript 按键脚本，每行为：
+//
+//	CYCLE KEY [HOLD]
+//
+// CYCLE为从Reset开始执行的指令数，+N表示上一行的按键松开（或没有HOLD时按下）之后N条指令。
+// KEY为单个可打印字符、#N形式的键码或按键名（ENTER、BACKSPACE、LEFT、UP、RIGHT、DOWN、HOME、
+// END、PAGEUP、PAGEDOWN、INSERT、DELETE、ESC、F1..F12、SPACE、RELEASE）。
+// 指定HOLD时按键保持HOLD条指令后松开，否则一直按住直到下一次按键
+type KeyScript struct {
+	Events []KeyEvent
+}
+
+// ParseKey 解析按键脚本中的KEY
+func ParseKey(text string) (uint16, error) {
+	if code, ok := keyNames[strings.ToUpper(text)]; ok && len(text) > 1 {
+		return code, nil
+	}
+	if strings.HasPrefix(text, "#") && len(text) > 1 {
+		code, err := strconv.ParseUint(text[1:], 10, 16)
+		if err != nil || code > 152 {
+			return 0, fmt.Errorf("invalid key code '%s', expect 0..152", text)
+		}
+		return uint16(code), nil
+	}
+	if len(text) == 1 && text[0] > ' ' && text[0] <= '~' {
+		return uint16(text[0]), nil
+	}
+	return 0, fmt.Errorf("unknown key '%s'", text)
+}
+
+// ParseKeyScript 解析按键脚本，按CYCLE排序，错误信息带有文件名和行号
+func ParseKeyScript(filename string, reader io.Reader) (*KeyScript, error) {
+	script := &KeyScript{}
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	var last int64
+	for scanner.Scan() {
+		lineNo += 1
+		line := scanner.Text()
+		if index := strings.Index(line, "//"); index != -1 {
+			line = line[:index]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", filename, lineNo, fmt.Sprintf(format, args...))
+		}
+		if len(fields) > 3 {
+			return nil, errorf("expect CYCLE KEY [HOLD]")
+		}
+		relative := strings.HasPrefix(fields[0], "+")
+		cycle, err := strconv.ParseInt(strings.TrimPrefix(fields[0], "+"), 10, 64)
+		if err != nil || cycle < 0 {
+			return nil, errorf("invalid cycle '%s'", fields[0])
+		}
+		if relative {
+			cycle += last
+		}
+		if len(fields) < 2 {
+			return nil, errorf("missing key")
+		}
+		key, err := ParseKey(fields[1])
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+		script.Events = append(script.Events, KeyEvent{Cycle: cycle, Key: key})
+		last = cycle
+		if len(fields) == 3 {
+			hold, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil || hold <= 0 {
+				return nil, errorf("invalid hold '%s'", fields[2])
+			}
+			last = cycle + hold
+			script.Events = append(script.Events, KeyEvent{Cycle: last, Key: 0})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(script.Events, func(i, j int) bool {
+		return script.Events[i].Cycle < script.Events[j].Cycle
+	})
+	return script, nil
+}
+
+// SetKeyScript 执行指令时按script设置KBD，Reset之后从头重放
+func (c *Computer) SetKeyScript(script *KeyScript) {
+	c.keys = script
+	c.nextKey = 0
+}
+
+// pressKeys 将到期的按键写入KBD
+func (c *Computer) pressKeys() {
+	for c.nextKey < len(c.keys.Events) && c.keys.Events[c.nextKey].Cycle <= c.Cycles {
+		c.RAM[KeyboardAddress] = c.keys.Events[c.nextKey].Key
+		c.nextKey += 1
+	}
+}
@@ -0,0 +1,81 @@
+package emulator
+
+import (
+	"strings"
+	"testing"
+
+	"nand2tetris/06/assembler/hackasm"
+)
+
+func TestParseKeyScript(t *testing.T) {
+	script, err := ParseKeyScript("test.keys", strings.NewReader(`
+// cycle key [hold]
+100 A 50      // 'A' for 50 cycles
++10 ENTER
+300 #152
++5 RELEASE
+200 f1
+`))
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	expect := []KeyEvent{{100, 'A'}, {150, 0}, {160, 128}, {200, 141}, {300, 152}, {305, 0}}
+	if len(script.Events) != len(expect) {
+		t.Fatalf("expect %v, got %v", expect, script.Events)
+	}
+	for i, event := range expect {
+		if script.Events[i] != event {
+			t.Errorf("event %d: expect %v, got %v", i, event, script.Events[i])
+		}
+	}
+	for _, bad := range []string{"10", "x A", "10 #153", "10 A 0", "10 AB"} {
+		if _, err := ParseKeyScript("bad.keys", strings.NewReader(bad)); err == nil {
+			t.Errorf("expect error for %q", bad)
+		}
+	}
+}
+
+func TestKeyScript(t *testing.T) {
+	// 等待按键，将键码写入R0后停机
+	program, _, err := hackasm.Assemble(strings.NewReader(`
+(WAIT)
+	@KBD
+	D=M
+	@WAIT
+	D;JEQ
+	@R0
+	M=D
+(END)
+	@END
+	0;JMP
+`))
+	if err != nil {
+		t.Fatalf("assemble err: %v", err)
+	}
+	c, err := NewComputer(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetKeyScript(&KeyScript{Events: []KeyEvent{{100, 'K'}, {150, 0}}})
+	for round := 0; round < 2; round++ {
+		if _, err := c.Run(100); err != nil {
+			t.Fatalf("run err: %v", err)
+		}
+		if c.RAM[0] != 0 {
+			t.Fatalf("round %d: expect no key before cycle 100, got %d", round, c.RAM[0])
+		}
+		reason, err := c.Run(0)
+		if err != nil {
+			t.Fatalf("run err: %v", err)
+		}
+		if reason != StopHalted || c.RAM[0] != 'K' || c.RAM[KeyboardAddress] != 'K' {
+			t.Fatalf("round %d: expect 'K' in R0 and KBD, got %d, %d", round, c.RAM[0], c.RAM[KeyboardAddress])
+		}
+		if c.Cycles > 110 {
+			t.Errorf("round %d: expect to see the key soon after cycle 100, got %d", round, c.Cycles)
+		}
+		c.Reset()
+		c.RAM[0] = 0
+		c.RAM[KeyboardAddress] = 0
+	}
+}
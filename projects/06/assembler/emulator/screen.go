@@ -0,0 +1,95 @@
+package emulator
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+)
+
+const (
+	ScreenWidth  = 512
+	ScreenHeight = 256
+)
+
+// screenPalette 下标0为白色背景，1为黑色像素
+var screenPalette = color.Palette{color.White, color.Black}
+
+// Screen 将SCREEN开始的8K内存渲染为512x256的黑白图像。
+// 每行32个字，每个字的最低位是最左边的像素，为1时显示黑色
+func (c *Computer) Screen() *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, ScreenWidth, ScreenHeight), screenPalette)
+	for row := 0; row < ScreenHeight; row++ {
+		for col := 0; col < ScreenWidth/16; col++ {
+			word := c.RAM[ScreenAddress+row*ScreenWidth/16+col]
+			for bit := 0; bit < 16; bit++ {
+				if word&(1<<bit) != 0 {
+					img.Pix[row*img.Stride+col*16+bit] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WriteScreenPNG 以PNG格式输出当前屏幕
+func (c *Computer) WriteScreenPNG(writer io.Writer) error {
+	return png.Encode(writer, c.Screen())
+}
+
+// Recorder 每隔一定的指令数记录一帧屏幕，生成GIF动画。
+// 连续相同的帧会合并为一帧，延长其显示时间
+type Recorder struct {
+	// Every 两帧之间执行的指令数
+	Every int64
+	// Delay 每帧的显示时间，单位为1/100秒
+	Delay int
+
+	animation gif.GIF
+	last      *image.Paletted
+}
+
+func NewRecorder(every int64, delay int) *Recorder {
+	return &Recorder{
+		Every: every,
+		Delay: delay,
+	}
+}
+
+// Capture 记录c当前的屏幕
+func (r *Recorder) Capture(c *Computer) {
+	frame := c.Screen()
+	if r.last != nil && string(r.last.Pix) == string(frame.Pix) {
+		r.animation.Delay[len(r.animation.Delay)-1] += r.Delay
+		return
+	}
+	r.animation.Image = append(r.animation.Image, frame)
+	r.animation.Delay = append(r.animation.Delay, r.Delay)
+	r.last = frame
+}
+
+// Frames 已经记录的帧数
+func (r *Recorder) Frames() int {
+	return len(r.animation.Image)
+}
+
+// Run 与Computer.Run相同，但是每执行Every条指令记录一帧，开始和结束时也各记录一帧。
+// 停止条件逐条指令检查，与帧的间隔无关
+func (r *Recorder) Run(c *Computer, maxCycles int64) (StopReason, error) {
+	r.Capture(c)
+	defer r.Capture(c)
+	if r.Every <= 0 {
+		return c.Run(maxCycles)
+	}
+	return c.run(maxCycles, func(executed int64) {
+		if executed%r.Every == 0 {
+			r.Capture(c)
+		}
+	})
+}
+
+// WriteGIF 输出记录的动画
+func (r *Recorder) WriteGIF(writer io.Writer) error {
+	return gif.EncodeAll(writer, &r.animation)
+}
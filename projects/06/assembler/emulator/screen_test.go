@@ -0,0 +1,167 @@
+package emulator
+
+import (
+	"bytes"
+	"flag"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"nand2tetris/06/assembler/hackasm"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// checkGolden 比较屏幕与testdata中的PNG，-update时重新生成
+func checkGolden(t *testing.T, c *Computer, golden string) {
+	var buf bytes.Buffer
+	if err := c.WriteScreenPNG(&buf); err != nil {
+		t.Fatalf("write png err: %v", err)
+	}
+	if *update {
+		if err := ioutil.WriteFile(golden, buf.Bytes(), 0666); err != nil {
+			t.Fatalf("update %s err: %v", golden, err)
+		}
+		return
+	}
+	f, err := os.Open(golden)
+	if err != nil {
+		t.Fatalf("open %s err: %v, run go test -update to create it", golden, err)
+	}
+	defer f.Close()
+	expect, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %s err: %v", golden, err)
+	}
+	got := c.Screen()
+	for y := 0; y < ScreenHeight; y++ {
+		for x := 0; x < ScreenWidth; x++ {
+			er, _, _, _ := expect.At(x, y).RGBA()
+			gr, _, _, _ := got.At(x, y).RGBA()
+			if er != gr {
+				t.Fatalf("screen differs from %s at (%d, %d)", golden, x, y)
+			}
+		}
+	}
+}
+
+func TestRectScreen(t *testing.T) {
+	c := loadComputer(t, "../../../05/Rect.hack")
+	c.RAM[0] = 40
+	if _, err := c.Run(0); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	checkGolden(t, c, "testdata/Rect.png")
+}
+
+// assembleComputer 汇编path处的.asm并加载到新的计算机中，按键脚本为keys
+func assembleComputer(t *testing.T, path string, keys string) *Computer {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s err: %v", path, err)
+	}
+	defer f.Close()
+	program, _, err := hackasm.Assemble(f)
+	if err != nil {
+		t.Fatalf("assemble %s err: %v", path, err)
+	}
+	c, err := NewComputer(program)
+	if err != nil {
+		t.Fatalf("new computer err: %v", err)
+	}
+	script, err := ParseKeyScript("", strings.NewReader(keys))
+	if err != nil {
+		t.Fatalf("parse key script err: %v", err)
+	}
+	c.SetKeyScript(script)
+	return c
+}
+
+// TestFillScreen 按住按键涂黑屏幕的上半部分，松开后从下往上擦掉一部分
+func TestFillScreen(t *testing.T) {
+	c := assembleComputer(t, "../../../04/fill/Fill.asm", "0 K 150000\n")
+	if _, err := c.Run(200000); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	checkGolden(t, c, "testdata/Fill.png")
+}
+
+// TestPongScreen 游戏画面出现之后先向左再向右移动球拍，球拍的位置与不按键时不同
+func TestPongScreen(t *testing.T) {
+	const cycles = 12000000
+	idle := assembleComputer(t, "../../pong/Pong.asm", "")
+	if _, err := idle.Run(cycles); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	c := assembleComputer(t, "../../pong/Pong.asm", "6000000 LEFT 3000000\n+1000000 RIGHT 1000000\n")
+	if _, err := c.Run(cycles); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	if bytes.Equal(idle.Screen().Pix, c.Screen().Pix) {
+		t.Errorf("expect the keys to move the paddle")
+	}
+	checkGolden(t, c, "testdata/Pong.png")
+}
+
+func TestRecorder(t *testing.T) {
+	c := loadComputer(t, "../../../05/Rect.hack")
+	c.RAM[0] = 8
+	recorder := NewRecorder(20, 2)
+	reason, err := recorder.Run(c, 0)
+	if err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	if reason != StopHalted {
+		t.Fatalf("expect halted, got %s", reason)
+	}
+	var buf bytes.Buffer
+	if err := recorder.WriteGIF(&buf); err != nil {
+		t.Fatalf("write gif err: %v", err)
+	}
+	animation, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("decode gif err: %v", err)
+	}
+	// 开始时的空白屏幕、绘制过程中的若干帧以及最终的屏幕
+	if len(animation.Image) < 3 || len(animation.Image) != recorder.Frames() {
+		t.Fatalf("expect at least 3 distinct frames, got %d", len(animation.Image))
+	}
+	last := animation.Image[len(animation.Image)-1]
+	if !bytes.Equal(last.Pix, c.Screen().Pix) {
+		t.Errorf("last frame differs from the final screen")
+	}
+}
+
+// TestRecorderBreakpoint 断点恰好落在两帧之间时也要停下
+func TestRecorderBreakpoint(t *testing.T) {
+	// D=D+1执行8次，然后 @8 0;JMP 停机
+	program := []uint16{}
+	for i := 0; i < 8; i++ {
+		program = append(program, 0xe7d0)
+	}
+	program = append(program, 8, 0xea87)
+	c, err := NewComputer(program)
+	if err != nil {
+		t.Fatalf("new computer err: %v", err)
+	}
+	c.SetBreakpoint(4)
+	recorder := NewRecorder(4, 2)
+	reason, err := recorder.Run(c, 0)
+	if err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	if reason != StopBreakpoint || c.PC != 4 || c.Cycles != 4 {
+		t.Fatalf("expect breakpoint at 4 after 4 cycles, got %s at %d after %d cycles", reason, c.PC, c.Cycles)
+	}
+	c.ClearBreakpoints()
+	reason, err = recorder.Run(c, 100)
+	if err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	if reason != StopHalted || c.D != 8 {
+		t.Fatalf("expect halted with D=8, got %s with D=%d", reason, int16(c.D))
+	}
+}
@@ -1,3 +1,3 @@
 module nand2tetris/06/assembler
 
-go 1.17
+go 1.18
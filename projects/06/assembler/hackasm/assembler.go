@@ -0,0 +1,228 @@
+package hackasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Options 汇编选项
+type Options struct {
+	// Extended 接受扩展指令集：移位运算以及交换操作数顺序的写法，如A+D
+	Extended bool
+	// Optimize 编码前对指令做窥孔优化，统计结果记录在Program.Optimization中
+	Optimize bool
+	// VariableBase 第一个变量的RAM地址，0表示默认的16
+	VariableBase int
+	// VariableLimit 变量地址的上限（不含），0表示默认的16384，即SCREEN
+	VariableLimit int
+}
+
+// variableWindow 返回变量可用的RAM地址范围[base, limit)
+func (o Options) variableWindow() (int, int, error) {
+	base, limit := o.VariableBase, o.VariableLimit
+	if base == 0 {
+		base = VariableBase
+	}
+	if limit == 0 {
+		limit = VariableLimit
+	}
+	if base < 0 || limit > RAMSize || base >= limit {
+		return 0, 0, fmt.Errorf("invalid variable window %d..%d", base, limit)
+	}
+	return base, limit, nil
+}
+
+// Assemble 汇编reader中的代码，返回机器码和符号表
+func Assemble(reader io.Reader) ([]uint16, *SymbolTable, error) {
+	words, table, _, err := AssembleFile("", reader, Options{})
+	return words, table, err
+}
+
+// AssembleFile 按options汇编filename的内容，同时返回解析后的程序，用于生成列表文件等。
+// 遇到错误时会继续汇编，最后以ErrorList的形式返回全部错误
+func AssembleFile(filename string, reader io.Reader, options Options) ([]uint16, *SymbolTable, *Program, error) {
+	program, errs := parse(filename, reader)
+	for _, symbol := range program.Imports {
+		errs = append(errs, &AsmError{File: symbol.Pos.File, Line: symbol.Pos.Line, Col: symbol.Pos.Col, Text: symbol.Name,
+			Msg: "imported symbol needs linking, assemble into an object and link it"})
+	}
+	if options.Optimize && len(errs) == 0 {
+		stats, err := program.Optimize()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		program.Optimization = &stats
+	}
+	words, table, err := NewEncoder(options).Encode(program.Instructions)
+	if encodeErrs, ok := err.(ErrorList); ok {
+		errs = append(errs, encodeErrs...)
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	return words, table, program, nil
+}
+
+// Encoder 将指令翻译为机器码
+type Encoder struct {
+	options Options
+}
+
+func NewEncoder(options Options) *Encoder {
+	return &Encoder{
+		options: options,
+	}
+}
+
+// Encode 第一遍收集标签的ROM地址，第二遍翻译指令并从VariableBase开始为变量分配RAM地址。
+// 指令超出ROM或变量超出[VariableBase, VariableLimit)时返回错误
+func (e *Encoder) Encode(instructions []Instruction) ([]uint16, *SymbolTable, error) {
+	base, limit, err := e.options.variableWindow()
+	if err != nil {
+		return nil, nil, err
+	}
+	table := NewSymbolTalbe()
+	table.SetVariableBase(base)
+	codeAddress, errs := collectLabels(instructions, &table)
+
+	words := make([]uint16, 0, codeAddress)
+	for _, ins := range instructions {
+		if ins.Type == L_COMMAND {
+			continue
+		}
+		next := table.NextVariableAddress()
+		word, err := e.EncodeInstruction(ins, &table)
+		if err != nil {
+			errs = append(errs, err...)
+			continue
+		}
+		if table.NextVariableAddress() > next && next == limit {
+			errs = append(errs, ins.errorf(ins.Symbol, "variable overflow, no free RAM left in %d..%d for more than %d variables", base, limit-1, limit-base))
+		}
+		words = append(words, word)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, nil, err
+	}
+	return words, &table, nil
+}
+
+// collectLabels 将标签及其ROM地址加入table，返回指令数
+func collectLabels(instructions []Instruction, table *SymbolTable) (int, ErrorList) {
+	var errs ErrorList
+	labelLines := map[string]Pos{}
+	codeAddress := 0
+	for _, ins := range instructions {
+		switch ins.Type {
+		case L_COMMAND:
+			if pos, ok := labelLines[ins.Symbol]; ok {
+				errs = append(errs, ins.errorf(ins.Symbol, "duplicate label, first defined at %s", pos))
+				continue
+			}
+			if table.Contains(ins.Symbol) {
+				errs = append(errs, ins.errorf(ins.Symbol, "label redefines predefined symbol"))
+				continue
+			}
+			labelLines[ins.Symbol] = ins.Pos
+			table.AddEntry(ins.Symbol, codeAddress)
+		case A_COMMAND, C_COMMAND:
+			if codeAddress == ROMSize {
+				errs = append(errs, ins.errorf("", "ROM overflow, program has more than %d instructions", ROMSize))
+			}
+			codeAddress += 1
+		}
+	}
+	return codeAddress, errs
+}
+
+// EncodeInstruction 翻译一条A指令或C指令，A指令中未定义的符号会被当作变量加入table
+func (e *Encoder) EncodeInstruction(ins Instruction, table *SymbolTable) (uint16, ErrorList) {
+	switch ins.Type {
+	case A_COMMAND:
+		if isConstant(ins.Symbol) {
+			val, err := parseConstant(ins.Symbol)
+			if err != nil {
+				return 0, ErrorList{ins.errorf(ins.Symbol, "%v", err)}
+			}
+			return uint16(val), nil
+		}
+		if !isSymbol(ins.Symbol) {
+			return 0, ErrorList{ins.errorf(ins.Symbol, "invalid symbol")}
+		}
+		if !table.Contains(ins.Symbol) {
+			table.AddVariable(ins.Symbol)
+		}
+		return uint16(table.GetAddress(ins.Symbol)), nil
+	case C_COMMAND:
+		return e.encodeC(ins)
+	}
+	return 0, ErrorList{ins.errorf("", "not an A or C instruction")}
+}
+
+func (e *Encoder) encodeC(ins Instruction) (uint16, ErrorList) {
+	var errs ErrorList
+	prefix, compCode, err := lookupComp(ins.Comp, e.options.Extended)
+	if err != nil {
+		errs = append(errs, ins.errorf(ins.Comp, "%v", err))
+	}
+	dest := ins.Dest
+	if dest == "" {
+		dest = "null"
+	}
+	destCode, ok := destRegMap[dest]
+	if !ok {
+		errs = append(errs, ins.errorf(dest, "unknown dest mnemonic"))
+	}
+	jump := string(ins.Jump)
+	if jump == "" {
+		jump = string(Null)
+	}
+	jumpCode, ok := jumpMap[jump]
+	if !ok {
+		errs = append(errs, ins.errorf(jump, "unknown jump mnemonic"))
+	}
+	if len(errs) > 0 {
+		return 0, errs
+	}
+	word, _ := strconv.ParseUint(prefix+compCode+destCode+jumpCode, 2, 16)
+	return uint16(word), nil
+}
+
+// WriteHack 以.hack格式输出机器码，每行一个16位的二进制数
+func WriteHack(writer io.Writer, words []uint16) error {
+	bufWriter := bufio.NewWriter(writer)
+	for _, word := range words {
+		bufWriter.WriteString(fmt.Sprintf("%016b\n", word))
+	}
+	return bufWriter.Flush()
+}
+
+// Listing 将程序的每一行源码与其ROM地址和机器码对应起来，用于WriteListing。
+// 源码是原始文件中的一行而不是展开后的代码，一行宏调用展开的多条指令只在第一条显示源码
+func (p *Program) Listing(words []uint16) []ListingLine {
+	lines := make([]ListingLine, 0, len(p.Lines))
+	address := 0
+	var last Origin
+	for i, line := range p.Lines {
+		continued := i > 0 && line.Origin.File == last.File && line.Origin.Line == last.Line
+		last = line.Origin
+		listingLine := ListingLine{Address: -1}
+		if !continued {
+			listingLine.Source = line.Origin.Text
+		}
+		if line.Instruction >= 0 && p.Instructions[line.Instruction].Type != L_COMMAND && address < len(words) {
+			listingLine.Address = address
+			listingLine.Word = words[address]
+			address += 1
+		} else if continued {
+			// 宏中的标签和注释
+			continue
+		}
+		lines = append(lines, listingLine)
+	}
+	return lines
+}
@@ -0,0 +1,203 @@
+package hackasm
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the regression snapshots in testdata, the course .hack files in 05 are never rewritten")
+
+// courseGoldens 课程程序及其期望的机器码。
+// 05中的Add、Max、Rect的.hack由课程提供，是检查汇编结果正确性的依据；
+// testdata/Pong.hack不是课程文件，而是本汇编器用-update生成的回归快照，
+// 只保证Pong.asm和PongL.asm的结果一致且不意外改变，更新前须确认改动是有意的
+var courseGoldens = []struct {
+	source string
+	golden string
+}{
+	{"../../add/Add.asm", "../../../05/Add.hack"},
+	{"../../max/Max.asm", "../../../05/Max.hack"},
+	{"../../max/MaxL.asm", "../../../05/Max.hack"},
+	{"../../rect/Rect.asm", "../../../05/Rect.hack"},
+	{"../../rect/RectL.asm", "../../../05/Rect.hack"},
+	{"../../pong/Pong.asm", "testdata/Pong.hack"},
+	{"../../pong/PongL.asm", "testdata/Pong.hack"},
+}
+
+func assembleFile(t *testing.T, path string) []uint16 {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s err: %v", path, err)
+	}
+	defer f.Close()
+	words, _, _, err := AssembleFile(path, f, Options{})
+	if err != nil {
+		t.Fatalf("assemble %s err: %v", path, err)
+	}
+	return words
+}
+
+func hackText(words []uint16) []byte {
+	var buf bytes.Buffer
+	if err := WriteWords(&buf, words, FORMAT_HACK); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCoursePrograms(t *testing.T) {
+	for _, test := range courseGoldens {
+		got := hackText(assembleFile(t, test.source))
+		if *update && strings.HasPrefix(test.golden, "testdata/") {
+			if err := ioutil.WriteFile(test.golden, got, 0666); err != nil {
+				t.Fatalf("update %s err: %v", test.golden, err)
+			}
+			continue
+		}
+		expect, err := ioutil.ReadFile(test.golden)
+		if err != nil {
+			t.Fatalf("read %s err: %v", test.golden, err)
+		}
+		expect = bytes.ReplaceAll(expect, []byte("\r\n"), []byte("\n"))
+		if !bytes.Equal(got, expect) {
+			gotLines, expectLines := strings.Split(string(got), "\n"), strings.Split(string(expect), "\n")
+			for i := 0; i < len(gotLines) && i < len(expectLines); i++ {
+				if gotLines[i] != expectLines[i] {
+					t.Errorf("%s: word %d differs from %s: expect %s, got %s", test.source, i, test.golden, expectLines[i], gotLines[i])
+					break
+				}
+			}
+			if len(gotLines) != len(expectLines) {
+				t.Errorf("%s: expect %d lines as %s, got %d", test.source, len(expectLines), test.golden, len(gotLines))
+			}
+		}
+	}
+}
+
+// roundTrip 反汇编words再汇编，要求得到相同的机器码，且再次反汇编得到相同的代码
+func roundTrip(t *testing.T, name string, words []uint16) {
+	disassemble := func(words []uint16) string {
+		var out strings.Builder
+		if err := Disassemble(name, bytes.NewReader(hackText(words)), &out, NewSymbolMap()); err != nil {
+			t.Fatalf("%s: disassemble err: %v", name, err)
+		}
+		return out.String()
+	}
+	source := disassemble(words)
+	again, _, _, err := AssembleFile(name, strings.NewReader(source), Options{Extended: true})
+	if err != nil {
+		t.Fatalf("%s: assemble the disassembly err: %v", name, err)
+	}
+	if len(again) != len(words) {
+		t.Fatalf("%s: expect %d words after the round trip, got %d", name, len(words), len(again))
+	}
+	for i := range words {
+		if again[i] != words[i] {
+			t.Fatalf("%s: word %d changed from %016b to %016b after the round trip", name, i, words[i], again[i])
+		}
+	}
+	if disassemble(again) != source {
+		t.Errorf("%s: disassembly is not a fixed point", name)
+	}
+}
+
+func TestRoundTripCoursePrograms(t *testing.T) {
+	for _, test := range courseGoldens {
+		roundTrip(t, test.source, assembleFile(t, test.source))
+	}
+}
+
+// randomWord 随机的合法指令，包括扩展指令集中的移位运算
+func randomWord(r *rand.Rand) uint16 {
+	dest, jump := uint16(r.Intn(8)), uint16(r.Intn(8))
+	switch r.Intn(3) {
+	case 0:
+		return uint16(r.Intn(0x8000))
+	case 1:
+		codes := make([]string, 0, len(compInstructMap))
+		for _, code := range compInstructMap {
+			codes = append(codes, code)
+		}
+		return 0xe000 | parseBits(codes[r.Intn(len(codes))])<<6 | dest<<3 | jump
+	default:
+		codes := make([]string, 0, len(shiftCompInstructMap))
+		for _, code := range shiftCompInstructMap {
+			codes = append(codes, code)
+		}
+		return 0xa000 | parseBits(codes[r.Intn(len(codes))])<<6 | dest<<3 | jump
+	}
+}
+
+func parseBits(bits string) uint16 {
+	var value uint16
+	for _, c := range bits {
+		value = value<<1 | uint16(c-'0')
+	}
+	return value
+}
+
+func TestRoundTripRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 50; n++ {
+		words := make([]uint16, 1+r.Intn(200))
+		for i := range words {
+			words[i] = randomWord(r)
+		}
+		roundTrip(t, "random", words)
+	}
+}
+
+func TestROMOverflow(t *testing.T) {
+	program := func(n int) string {
+		return "(START)\n" + strings.Repeat("D=D+1\n", n-1) + "@START\n(END)"
+	}
+	words, _, err := Assemble(strings.NewReader(program(ROMSize)))
+	if err != nil || len(words) != ROMSize {
+		t.Fatalf("expect %d instructions to fit, got %d words, err %v", ROMSize, len(words), err)
+	}
+	_, _, err = Assemble(strings.NewReader(program(ROMSize + 1)))
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Line != ROMSize+2 || !strings.Contains(errs[0].Msg, "ROM overflow") {
+		t.Fatalf("expect one ROM overflow error on line %d, got %v", ROMSize+2, err)
+	}
+}
+
+func TestVariableWindow(t *testing.T) {
+	source := "@a\n@b\n@a\n@c"
+	words, table, _, err := AssembleFile("", strings.NewReader(source), Options{VariableBase: 100, VariableLimit: 103})
+	if err != nil {
+		t.Fatalf("expect 3 variables in 100..102, got %v", err)
+	}
+	if words[0] != 100 || words[3] != 102 || table.GetAddress("b") != 101 {
+		t.Errorf("expect a, b, c at 100, 101, 102, got %v", words)
+	}
+
+	_, _, _, err = AssembleFile("", strings.NewReader(source), Options{VariableBase: 100, VariableLimit: 102})
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Line != 4 || errs[0].Text != "c" || !strings.Contains(errs[0].Msg, "variable overflow") {
+		t.Fatalf("expect a variable overflow for c on line 4, got %v", err)
+	}
+
+	// 默认窗口为16..16383
+	source = ""
+	for i := VariableBase; i <= VariableLimit; i++ {
+		source += fmt.Sprintf("@v%d\n", i)
+	}
+	_, _, err = Assemble(strings.NewReader(source))
+	errs, ok = err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Text != fmt.Sprintf("v%d", VariableLimit) {
+		t.Fatalf("expect an overflow for the variable after RAM[%d], got %v", VariableLimit-1, err)
+	}
+
+	for _, options := range []Options{{VariableBase: 200, VariableLimit: 100}, {VariableBase: -1}, {VariableLimit: RAMSize + 1}} {
+		if _, _, _, err := AssembleFile("", strings.NewReader("@a"), options); err == nil {
+			t.Errorf("%+v: expect an invalid window error", options)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package hackasm
+
+import (
+	"fmt"
+)
+
+var jumpMap = map[string]string{
+	"null": "000",
+	"JGT":  "001",
+	"JEQ":  "010",
+	"JGE":  "011",
+	"JLT":  "100",
+	"JNE":  "101",
+	"JLE":  "110",
+	"JMP":  "111",
+}
+
+var destRegMap = map[string]string{
+	"null": "000",
+	"M":    "001",
+	"D":    "010",
+	"MD":   "011",
+	"A":    "100",
+	"AM":   "101",
+	"AD":   "110",
+	"AMD":  "111",
+}
+
+var compInstructMap = map[string]string{
+	"0":   "0101010",
+	"1":   "0111111",
+	"-1":  "0111010",
+	"D":   "0001100",
+	"A":   "0110000",
+	"M":   "1110000",
+	"!D":  "0001101",
+	"!A":  "0110001",
+	"!M":  "1110001",
+	"-D":  "0001111",
+	"-A":  "0110011",
+	"-M":  "1110011",
+	"D+1": "0011111",
+	"A+1": "0110111",
+	"M+1": "1110111",
+	"D-1": "0001110",
+	"A-1": "0110010",
+	"M-1": "1110010",
+	"D+A": "0000010",
+	"D+M": "1000010",
+	"D-A": "0010011",
+	"D-M": "1010011",
+	"A-D": "0000111",
+	"M-D": "1000111",
+	"D&A": "0000000",
+	"D&M": "1000000",
+	"D|A": "0010101",
+	"D|M": "1010101",
+}
+
+// commutedCompInstructMap 扩展指令集中交换操作数顺序的写法，编码与原写法相同
+var commutedCompInstructMap = map[string]string{
+	"A+D": "0000010",
+	"M+D": "1000010",
+	"A&D": "0000000",
+	"M&D": "1000000",
+	"A|D": "0010101",
+	"M|D": "1010101",
+}
+
+// shiftCompInstructMap 扩展指令集中的移位运算，指令前缀为101。
+// <<为逻辑左移一位，>>为算术右移一位
+var shiftCompInstructMap = map[string]string{
+	"D<<": "0110000",
+	"A<<": "0100000",
+	"M<<": "1100000",
+	"D>>": "0010000",
+	"A>>": "0000000",
+	"M>>": "1000000",
+}
+
+// lookupComp 返回comp对应的指令前缀和7位编码，扩展写法只在extended为true时接受
+func lookupComp(comp string, extended bool) (string, string, error) {
+	if code, ok := compInstructMap[comp]; ok {
+		return "111", code, nil
+	}
+	if code, ok := commutedCompInstructMap[comp]; ok {
+		if !extended {
+			return "", "", fmt.Errorf("commuted comp mnemonic requires the extended instruction set (-x)")
+		}
+		return "111", code, nil
+	}
+	if code, ok := shiftCompInstructMap[comp]; ok {
+		if !extended {
+			return "", "", fmt.Errorf("shift comp mnemonic requires the extended instruction set (-x)")
+		}
+		return "101", code, nil
+	}
+	return "", "", fmt.Errorf("unknown comp mnemonic")
+}
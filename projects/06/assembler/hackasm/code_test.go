@@ -0,0 +1,128 @@
+package hackasm
+
+import (
+	"strings"
+	"testing"
+)
+
+// specComp 课程规范中comp的a位和c1..c6，与code.go中的表分开维护
+var specComp = map[string]uint16{
+	"0": 0b0101010, "1": 0b0111111, "-1": 0b0111010,
+	"D": 0b0001100, "A": 0b0110000, "M": 0b1110000,
+	"!D": 0b0001101, "!A": 0b0110001, "!M": 0b1110001,
+	"-D": 0b0001111, "-A": 0b0110011, "-M": 0b1110011,
+	"D+1": 0b0011111, "A+1": 0b0110111, "M+1": 0b1110111,
+	"D-1": 0b0001110, "A-1": 0b0110010, "M-1": 0b1110010,
+	"D+A": 0b0000010, "D+M": 0b1000010,
+	"D-A": 0b0010011, "D-M": 0b1010011,
+	"A-D": 0b0000111, "M-D": 0b1000111,
+	"D&A": 0b0000000, "D&M": 0b1000000,
+	"D|A": 0b0010101, "D|M": 0b1010101,
+}
+
+var specDests = []string{"", "M", "D", "MD", "A", "AM", "AD", "AMD"}
+
+var specJumps = []string{"", "JGT", "JEQ", "JGE", "JLT", "JNE", "JLE", "JMP"}
+
+// destBits 按寄存器逐位计算dest：A为d1，D为d2，M为d3
+func destBits(dest string) uint16 {
+	var bits uint16
+	for i, register := range "ADM" {
+		if strings.ContainsRune(dest, register) {
+			bits |= 1 << (2 - i)
+		}
+	}
+	return bits
+}
+
+// jumpBits 按条件计算jump：j1为out<0，j2为out=0，j3为out>0
+func jumpBits(jump string) uint16 {
+	var bits uint16
+	if jump == "JMP" {
+		return 0b111
+	}
+	if strings.Contains(jump, "L") || jump == "JNE" {
+		bits |= 0b100
+	}
+	if strings.Contains(jump, "E") && jump != "JNE" {
+		bits |= 0b010
+	}
+	if strings.Contains(jump, "G") || jump == "JNE" {
+		bits |= 0b001
+	}
+	return bits
+}
+
+func cInstruction(dest, comp, jump string) string {
+	code := comp
+	if dest != "" {
+		code = dest + "=" + code
+	}
+	if jump != "" {
+		code += ";" + jump
+	}
+	return code
+}
+
+func TestCInstructions(t *testing.T) {
+	var source strings.Builder
+	var expect []uint16
+	for comp, compBits := range specComp {
+		for _, dest := range specDests {
+			for _, jump := range specJumps {
+				source.WriteString(cInstruction(dest, comp, jump) + "\n")
+				expect = append(expect, 0xe000|compBits<<6|destBits(dest)<<3|jumpBits(jump))
+			}
+		}
+	}
+	words, _, err := Assemble(strings.NewReader(source.String()))
+	if err != nil {
+		t.Fatalf("assemble err: %v", err)
+	}
+	if len(words) != len(expect) {
+		t.Fatalf("expect %d words, got %d", len(expect), len(words))
+	}
+	lines := strings.Split(source.String(), "\n")
+	for i := range expect {
+		if words[i] != expect[i] {
+			t.Errorf("%s: expect %016b, got %016b", lines[i], expect[i], words[i])
+		}
+	}
+}
+
+func TestExtendedInstructions(t *testing.T) {
+	tests := []struct {
+		code   string
+		expect uint16
+	}{
+		{"D=A+D", 0xe000 | 0b0000010<<6 | 0b010<<3},
+		{"M=M&D", 0xe000 | 0b1000000<<6 | 0b001<<3},
+		{"A|D;JNE", 0xe000 | 0b0010101<<6 | 0b101},
+		{"D=D<<", 0xa000 | 0b0110000<<6 | 0b010<<3},
+		{"AM=M>>", 0xa000 | 0b1000000<<6 | 0b101<<3},
+		{"A<<;JMP", 0xa000 | 0b0100000<<6 | 0b111},
+	}
+	for _, test := range tests {
+		words, _, _, err := AssembleFile("", strings.NewReader(test.code), Options{Extended: true})
+		if err != nil {
+			t.Errorf("%s: assemble err: %v", test.code, err)
+			continue
+		}
+		if words[0] != test.expect {
+			t.Errorf("%s: expect %016b, got %016b", test.code, test.expect, words[0])
+		}
+		if _, _, err := Assemble(strings.NewReader(test.code)); err == nil {
+			t.Errorf("%s: expect an error without the extended instruction set", test.code)
+		}
+	}
+}
+
+func TestInvalidCInstructions(t *testing.T) {
+	for _, code := range []string{"D=X", "Q=D", "D;JXX", "=D", "D;", "D=M;JMP;JMP", "MM=D", "D+D"} {
+		_, _, err := Assemble(strings.NewReader(code))
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 || errs[0].Line != 1 {
+			t.Errorf("%s: expect one error on line 1, got %v", code, err)
+		}
+	}
+}
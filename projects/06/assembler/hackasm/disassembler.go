@@ -0,0 +1,263 @@
+package hackasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+var (
+	compMnemonicMap = invertMap(compInstructMap)
+	destMnemonicMap = invertMap(destRegMap)
+	jumpMnemonicMap = invertMap(jumpMap)
+	// shiftMnemonicMap 扩展指令集（前缀101）的移位运算
+	shiftMnemonicMap = invertMap(shiftCompInstructMap)
+)
+
+func invertMap(m map[string]string) map[string]string {
+	inverted := make(map[string]string, len(m))
+	for k, v := range m {
+		inverted[v] = k
+	}
+	return inverted
+}
+
+// SymbolMap 符号表文件中的符号，label对应ROM地址，variable对应RAM地址
+type SymbolMap struct {
+	labels    map[int]string
+	variables map[int]string
+	// allLabels 同一地址可能有多个标签，labels中只保留最后一个
+	allLabels map[int][]string
+}
+
+func NewSymbolMap() SymbolMap {
+	return SymbolMap{
+		labels:    map[int]string{},
+		variables: map[int]string{},
+		allLabels: map[int][]string{},
+	}
+}
+
+// Labels 符号表文件中的标签，ROM地址到该地址的所有标签名
+func (s SymbolMap) Labels() map[int][]string {
+	labels := make(map[int][]string, len(s.allLabels))
+	for address, names := range s.allLabels {
+		labels[address] = append([]string(nil), names...)
+	}
+	return labels
+}
+
+// ReadSymbolMap 读取符号表文件，每行格式为 "符号 地址 [类型]"，
+// 类型为label、variable或predefined，省略类型时同时作为label和variable使用
+func ReadSymbolMap(filename string, reader io.Reader) (SymbolMap, error) {
+	symbols := NewSymbolMap()
+	var errs ErrorList
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo += 1
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "//") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			errs = append(errs, &AsmError{File: filename, Line: lineNo, Col: 1, Text: line, Msg: "expect 'symbol address [kind]'"})
+			continue
+		}
+		address, err := strconv.Atoi(fields[1])
+		if err != nil {
+			errs = append(errs, &AsmError{File: filename, Line: lineNo, Col: strings.Index(line, fields[1]) + 1, Text: fields[1], Msg: "invalid address"})
+			continue
+		}
+		kind := ""
+		if len(fields) == 3 {
+			kind = fields[2]
+		}
+		switch kind {
+		case "label":
+			symbols.labels[address] = fields[0]
+			symbols.allLabels[address] = append(symbols.allLabels[address], fields[0])
+		case "variable":
+			symbols.variables[address] = fields[0]
+		case "predefined":
+		case "":
+			symbols.labels[address] = fields[0]
+			symbols.allLabels[address] = append(symbols.allLabels[address], fields[0])
+			symbols.variables[address] = fields[0]
+		default:
+			errs = append(errs, &AsmError{File: filename, Line: lineNo, Col: strings.Index(line, kind) + 1, Text: kind, Msg: "unknown symbol kind"})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return symbols, err
+	}
+	return symbols, errs.Err()
+}
+
+// predefinedAddressSymbol A指令的值可以还原出的预定义符号。
+// SCREEN和KBD只按值判断；R0-R15与小的常量无法区分，只在下一条指令读写M时使用
+func predefinedAddressSymbol(address int, usesMemory bool) (string, bool) {
+	switch {
+	case address == 16384:
+		return "SCREEN", true
+	case address == 24576:
+		return "KBD", true
+	case usesMemory && address >= 0 && address <= 15:
+		return fmt.Sprintf("R%d", address), true
+	}
+	return "", false
+}
+
+type machineWord struct {
+	value uint16
+	line  int
+}
+
+func (w machineWord) isAInstruction() bool {
+	return w.value&0x8000 == 0
+}
+
+func (w machineWord) jumpBits() uint16 {
+	return w.value & 0x7
+}
+
+func (w machineWord) destBits() uint16 {
+	return (w.value >> 3) & 0x7
+}
+
+func (w machineWord) compBits() uint16 {
+	return (w.value >> 6) & 0x7f
+}
+
+// usesMemory C指令是否读写M，即前一条A指令的值是被当作地址使用的
+func (w machineWord) usesMemory() bool {
+	return !w.isAInstruction() && (w.compBits()&0x40 != 0 || w.destBits()&0x1 != 0)
+}
+
+func readMachineWords(filename string, reader io.Reader) ([]machineWord, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var words []machineWord
+	var errs ErrorList
+	for i, rawLine := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(rawLine)
+		if len(line) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(line, 2, 16)
+		if err != nil || len(line) != 16 {
+			errs = append(errs, &AsmError{File: filename, Line: i + 1, Col: strings.Index(rawLine, line) + 1, Text: line, Msg: "expect 16 binary digits"})
+			continue
+		}
+		words = append(words, machineWord{value: uint16(value), line: i + 1})
+	}
+	return words, errs.Err()
+}
+
+// Disassemble 将.hack机器码还原为带注释的汇编代码。
+// 紧跟着跳转指令的A指令会被当作跳转目标，为其生成 (L_xxxx) 形式的标签，
+// symbols中有对应名字时使用原来的名字
+func Disassemble(filename string, reader io.Reader, writer io.Writer, symbols SymbolMap) error {
+	words, err := readMachineWords(filename, reader)
+	if err != nil {
+		return err
+	}
+
+	// 第一遍：找出所有跳转目标
+	labels := map[int]string{}
+	for i, word := range words {
+		if !word.isAInstruction() || i+1 >= len(words) {
+			continue
+		}
+		next := words[i+1]
+		if next.isAInstruction() || next.jumpBits() == 0 {
+			continue
+		}
+		target := int(word.value)
+		if target > len(words) {
+			continue
+		}
+		if name, ok := symbols.labels[target]; ok {
+			labels[target] = name
+		} else {
+			labels[target] = fmt.Sprintf("L_%04d", target)
+		}
+	}
+
+	var errs ErrorList
+	bufWriter := bufio.NewWriter(writer)
+	for i, word := range words {
+		if label, ok := labels[i]; ok {
+			bufWriter.WriteString(fmt.Sprintf("(%s)\n", label))
+		}
+		var code string
+		if word.isAInstruction() {
+			code = disassembleA(words, i, labels, symbols)
+		} else {
+			code, err = disassembleC(word)
+			if err != nil {
+				errs = append(errs, &AsmError{File: filename, Line: word.line, Col: 1, Text: fmt.Sprintf("%016b", word.value), Msg: err.Error()})
+				continue
+			}
+		}
+		bufWriter.WriteString(fmt.Sprintf("   %-24s// %04d: %016b\n", code, i, word.value))
+	}
+	if label, ok := labels[len(words)]; ok {
+		bufWriter.WriteString(fmt.Sprintf("(%s)\n", label))
+	}
+	if err := errs.Err(); err != nil {
+		return err
+	}
+	return bufWriter.Flush()
+}
+
+func disassembleA(words []machineWord, i int, labels map[int]string, symbols SymbolMap) string {
+	value := int(words[i].value)
+	usesMemory := false
+	if i+1 < len(words) {
+		next := words[i+1]
+		if !next.isAInstruction() && next.jumpBits() != 0 {
+			if label, ok := labels[value]; ok {
+				return "@" + label
+			}
+		}
+		usesMemory = next.usesMemory()
+		if name, ok := symbols.variables[value]; ok && usesMemory {
+			return "@" + name
+		}
+	}
+	if name, ok := predefinedAddressSymbol(value, usesMemory); ok {
+		return "@" + name
+	}
+	return fmt.Sprintf("@%d", value)
+}
+
+func disassembleC(word machineWord) (string, error) {
+	var comp string
+	var ok bool
+	switch word.value & 0xe000 {
+	case 0xe000:
+		comp, ok = compMnemonicMap[fmt.Sprintf("%07b", word.compBits())]
+	case 0xa000:
+		comp, ok = shiftMnemonicMap[fmt.Sprintf("%07b", word.compBits())]
+	default:
+		return "", fmt.Errorf("invalid C instruction prefix")
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown comp bits %07b", word.compBits())
+	}
+	code := comp
+	if dest := destMnemonicMap[fmt.Sprintf("%03b", word.destBits())]; dest != "null" {
+		code = dest + "=" + code
+	}
+	if jump := jumpMnemonicMap[fmt.Sprintf("%03b", word.jumpBits())]; jump != "null" {
+		code = code + ";" + jump
+	}
+	return code, nil
+}
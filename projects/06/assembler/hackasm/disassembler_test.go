@@ -0,0 +1,118 @@
+package hackasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// disassembleCode 反汇编words，只保留标签和指令，去掉每行末尾的地址和机器码注释
+func disassembleCode(t *testing.T, words []uint16, symbols SymbolMap) string {
+	t.Helper()
+	var out strings.Builder
+	if err := Disassemble("", bytes.NewReader(hackText(words)), &out, symbols); err != nil {
+		t.Fatalf("disassemble err: %v", err)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func assembleString(t *testing.T, source string) []uint16 {
+	t.Helper()
+	words, _, _, err := AssembleFile("", strings.NewReader(source), Options{Extended: true})
+	if err != nil {
+		t.Fatalf("assemble err: %v", err)
+	}
+	return words
+}
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		expect string
+	}{
+		{"SCREEN as an address", "@SCREEN\nD=A", "@SCREEN\nD=A"},
+		{"SCREEN as memory", "@SCREEN\nM=-1", "@SCREEN\nM=-1"},
+		{"KBD as memory", "@KBD\nD=M", "@KBD\nD=M"},
+		{"KBD last", "D=0\n@KBD", "D=0\n@KBD"},
+		{"register as memory", "@R13\nM=D", "@R13\nM=D"},
+		{"small constant", "@5\nD=A", "@5\nD=A"},
+		{"small constant last", "D=0\n@5", "D=0\n@5"},
+		{"above the registers", "@16\nM=0", "@16\nM=0"},
+		{"SP is R0", "@SP\nAM=M-1", "@R0\nAM=M-1"},
+		{"jump target", "(LOOP)\n@LOOP\n0;JMP", "(L_0000)\n@L_0000\n0;JMP"},
+		{"jump forward", "@END\nD;JEQ\nD=0\n(END)\n@END\n0;JMP", "@L_0003\nD;JEQ\nD=0\n(L_0003)\n@L_0003\n0;JMP"},
+		{"jump to the end", "@END\n0;JMP\n(END)", "@L_0002\n0;JMP\n(L_0002)"},
+		{"jump past the end", "@9\n0;JMP", "@9\n0;JMP"},
+		{"jump to R0", "@0\n0;JMP", "(L_0000)\n@L_0000\n0;JMP"},
+		{"dest and jump", "@1\nAM=M+1;JGT\n@0\nD=A", "@L_0001\n(L_0001)\nAM=M+1;JGT\n@0\nD=A"},
+		{"shift", "D=D<<\nM=A>>", "D=D<<\nM=A>>"},
+	}
+	for _, test := range tests {
+		words := assembleString(t, test.source)
+		if got := disassembleCode(t, words, NewSymbolMap()); got != test.expect {
+			t.Errorf("%s: expect\n%s\ngot\n%s", test.name, test.expect, got)
+		}
+		roundTrip(t, test.name, words)
+	}
+}
+
+// TestDisassembleSymbolMap 用-sym输出的符号表还原标签和变量的名字
+func TestDisassembleSymbolMap(t *testing.T) {
+	source := "@i\nM=0\n(LOOP)\n@i\nM=M+1\nD=M\n@LOOP\nD;JLT\n@SCREEN\nD=A\n@i\nD=D+M\n(END)\n@END\n0;JMP"
+	words, table, _, err := AssembleFile("", strings.NewReader(source), Options{})
+	if err != nil {
+		t.Fatalf("assemble err: %v", err)
+	}
+	var sym bytes.Buffer
+	if err := WriteSymbols(&sym, table); err != nil {
+		t.Fatalf("write symbols err: %v", err)
+	}
+	symbols, err := ReadSymbolMap("", &sym)
+	if err != nil {
+		t.Fatalf("read symbols err: %v", err)
+	}
+	expect := "@i\nM=0\n(LOOP)\n@i\nM=M+1\nD=M\n@LOOP\nD;JLT\n@SCREEN\nD=A\n@i\nD=D+M\n(END)\n@END\n0;JMP"
+	if got := disassembleCode(t, words, symbols); got != expect {
+		t.Errorf("expect\n%s\ngot\n%s", expect, got)
+	}
+
+	// 没有类型的符号同时作为标签和变量
+	symbols, err = ReadSymbolMap("", strings.NewReader("LOOP 2\ni 16\n"))
+	if err != nil {
+		t.Fatalf("read symbols err: %v", err)
+	}
+	if got := disassembleCode(t, words, symbols); got != expect[:strings.Index(expect, "(END)")]+"(L_0011)\n@L_0011\n0;JMP" {
+		t.Errorf("untyped symbols: got\n%s", got)
+	}
+}
+
+func TestDisassembleErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{"0000000000000001\n10\n", "2:1: expect 16 binary digits: '10'"},
+		{"  000000000000000x", "1:3: expect 16 binary digits: '000000000000000x'"},
+		{"1000000000000000", "1:1: invalid C instruction prefix: '1000000000000000'"},
+		{"1110000001000000", "1:1: unknown comp bits 0000001: '1110000001000000'"},
+	}
+	for _, test := range tests {
+		err := Disassemble("", strings.NewReader(test.source), &strings.Builder{}, NewSymbolMap())
+		if err == nil || err.Error() != test.expect {
+			t.Errorf("%q: expect %q, got %v", test.source, test.expect, err)
+		}
+	}
+	_, err := ReadSymbolMap("prog.sym", strings.NewReader("A\nB x\nC 1 other\n"))
+	expect := "prog.sym:1:1: expect 'symbol address [kind]': 'A'\nprog.sym:2:3: invalid address: 'x'\nprog.sym:3:5: unknown symbol kind: 'other'"
+	if err == nil || err.Error() != expect {
+		t.Errorf("expect symbol map errors:\n%s\ngot:\n%v", expect, err)
+	}
+}
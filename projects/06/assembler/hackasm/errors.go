@@ -0,0 +1,57 @@
+package hackasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AsmError 记录汇编时某一行出现的问题，包括所在文件、行号、列号以及出错的原文
+type AsmError struct {
+	File string
+	Line int
+	Col  int
+	Text string
+	Msg  string
+}
+
+func (e *AsmError) Error() string {
+	pos := fmt.Sprintf("%d:%d", e.Line, e.Col)
+	if e.File != "" && e.Line == 0 {
+		pos = e.File
+	} else if e.File != "" {
+		pos = e.File + ":" + pos
+	}
+	if e.Text == "" {
+		return fmt.Sprintf("%s: %s", pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s: '%s'", pos, e.Msg, e.Text)
+}
+
+// ErrorList 汇编过程中收集到的所有错误，按文件、行号、列号排序
+type ErrorList []*AsmError
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, 0, len(l))
+	for _, e := range l {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err 没有错误时返回nil，否则返回按位置排好序的错误列表
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].File != l[j].File {
+			return l[i].File < l[j].File
+		}
+		if l[i].Line != l[j].Line {
+			return l[i].Line < l[j].Line
+		}
+		return l[i].Col < l[j].Col
+	})
+	return l
+}
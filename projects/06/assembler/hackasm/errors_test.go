@@ -0,0 +1,107 @@
+package hackasm
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestErrorListOrder(t *testing.T) {
+	errs := ErrorList{
+		{File: "b.asm", Line: 1, Col: 1, Msg: "b1"},
+		{File: "a.asm", Line: 3, Col: 1, Msg: "a3"},
+		{File: "b.asm", Line: 2, Col: 5, Msg: "b2:5"},
+		{File: "a.asm", Line: 1, Col: 4, Msg: "a1:4"},
+		{File: "b.asm", Line: 2, Col: 1, Msg: "b2:1"},
+		{File: "a.asm", Line: 1, Col: 2, Msg: "a1:2"},
+	}
+	sorted, ok := errs.Err().(ErrorList)
+	if !ok {
+		t.Fatalf("expect an ErrorList, got %T", errs.Err())
+	}
+	expect := []string{"a1:2", "a1:4", "a3", "b1", "b2:1", "b2:5"}
+	for i, e := range sorted {
+		if e.Msg != expect[i] {
+			t.Fatalf("expect errors in the order %v, got %v", expect, sorted)
+		}
+	}
+	if (ErrorList{}).Err() != nil {
+		t.Errorf("expect nil for no errors")
+	}
+}
+
+// TestErrorsCollected 一次汇编报告全部错误，每个错误都有正确的文件、行号和列号
+func TestErrorsCollected(t *testing.T) {
+	source := strings.Join([]string{
+		"(LOOP)",
+		"(LOOP)",
+		"(1BAD)",
+		"D=Q",
+		"#import F",
+		"@F",
+		"@a-b",
+		"AM=M+1;JXX",
+		"(END",
+		"@END",
+		"0;JMP",
+	}, "\n")
+	_, _, _, err := AssembleFile("prog.asm", strings.NewReader(source), Options{})
+	expect := []string{
+		"prog.asm:2:2: duplicate label, first defined at prog.asm:1:1: 'LOOP'",
+		"prog.asm:3:1: malformed label, invalid name: '(1BAD)'",
+		"prog.asm:4:3: unknown comp mnemonic: 'Q'",
+		"prog.asm:5:9: imported symbol needs linking, assemble into an object and link it: 'F'",
+		"prog.asm:7:2: invalid symbol: 'a-b'",
+		"prog.asm:8:8: unknown jump mnemonic: 'JXX'",
+		"prog.asm:9:1: malformed label, missing ')': '(END'",
+	}
+	if err == nil || err.Error() != strings.Join(expect, "\n") {
+		t.Errorf("expect errors:\n%s\ngot:\n%v", strings.Join(expect, "\n"), err)
+	}
+
+	// 链接时才能发现的未定义符号也按目标文件和行号报告
+	object, err := AssembleObject("main.asm", strings.NewReader("#import F\n@R0\nM=0\n@F\n0;JMP\n@G\nD=A\n#import G"), Options{})
+	if err != nil {
+		t.Fatalf("assemble object err: %v", err)
+	}
+	_, _, err = Link([]*Object{object}, Options{})
+	expect = []string{
+		"main.asm:4:1: unresolved symbol: 'F'",
+		"main.asm:6:1: unresolved symbol: 'G'",
+	}
+	if err == nil || err.Error() != strings.Join(expect, "\n") {
+		t.Errorf("expect link errors:\n%s\ngot:\n%v", strings.Join(expect, "\n"), err)
+	}
+}
+
+// TestErrorsNoPanic 任何输入都只能返回错误，不能崩溃
+func TestErrorsNoPanic(t *testing.T) {
+	inputs := []string{
+		"", "@", "(", ")", "()", "(()", "=", ";", "=;", "D=", "D;", ";JMP", "=D;JMP",
+		"@99999999999999999999", "@-1", "@0x", "@'", "@''", "@'ab'", "@\x00",
+		"(\xff\xfe)", "D=M;JMP;JMP", "D==M", "AMDX=M", "D=M // (", "// only a comment",
+		"#define", "#define X", "#include", "#include \"missing.asm\"", "#macro", "#macro M\nD=0",
+		"#endmacro", "#export", "#import", "#export X", "#unknown", "\t \r\n\r\n",
+		"0000000000000000\n1", "1111111111111111", "extern", "word x", "code 99999",
+	}
+	for _, input := range inputs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%q: panic: %v", input, r)
+				}
+			}()
+			AssembleFile("prog.asm", strings.NewReader(input), Options{})
+			AssembleFile("prog.asm", strings.NewReader(input), Options{Extended: true, Optimize: true})
+			if object, err := AssembleObject("prog.asm", strings.NewReader(input), Options{}); err == nil {
+				Link([]*Object{object}, Options{})
+			}
+			Lint(&Program{})
+			ReadObject("prog.o", strings.NewReader(input))
+			Disassemble("prog.hack", strings.NewReader(input), ioutil.Discard, NewSymbolMap())
+			if program, err := Parse("prog.asm", strings.NewReader(input)); err == nil {
+				Lint(program)
+			}
+		}()
+	}
+}
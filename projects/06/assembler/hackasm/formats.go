@@ -0,0 +1,130 @@
+package hackasm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OutputFormat 机器码的输出格式
+type OutputFormat string
+
+const (
+	// FORMAT_HACK 课程使用的.hack格式，每行一个16位的二进制字符串
+	FORMAT_HACK OutputFormat = "hack"
+	// FORMAT_BIN 按大端序紧密排列的16位二进制数据
+	FORMAT_BIN OutputFormat = "bin"
+	// FORMAT_IHEX Intel HEX，地址以字节为单位，每个字按大端序占两个字节
+	FORMAT_IHEX OutputFormat = "ihex"
+	// FORMAT_MEMH 每行一个4位十六进制数，可以被Verilog的$readmemh读取
+	FORMAT_MEMH OutputFormat = "memh"
+	// FORMAT_LOGISIM Logisim的ROM镜像（v2.0 raw）
+	FORMAT_LOGISIM OutputFormat = "logisim"
+)
+
+// OutputFormats 所有支持的输出格式
+var OutputFormats = []OutputFormat{FORMAT_HACK, FORMAT_BIN, FORMAT_IHEX, FORMAT_MEMH, FORMAT_LOGISIM}
+
+// ParseOutputFormat 根据名字返回输出格式
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	for _, format := range OutputFormats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	names := make([]string, 0, len(OutputFormats))
+	for _, format := range OutputFormats {
+		names = append(names, string(format))
+	}
+	return "", fmt.Errorf("unknown output format '%s', expect one of %s", name, strings.Join(names, ", "))
+}
+
+// WriteWords 按format输出机器码
+func WriteWords(writer io.Writer, words []uint16, format OutputFormat) error {
+	switch format {
+	case FORMAT_HACK:
+		return WriteHack(writer, words)
+	case FORMAT_BIN:
+		return WriteBinary(writer, words)
+	case FORMAT_IHEX:
+		return WriteIntelHex(writer, words)
+	case FORMAT_MEMH:
+		return WriteMemh(writer, words)
+	case FORMAT_LOGISIM:
+		return WriteLogisim(writer, words)
+	}
+	return fmt.Errorf("unknown output format '%s'", format)
+}
+
+// WriteBinary 每个字按大端序输出两个字节
+func WriteBinary(writer io.Writer, words []uint16) error {
+	bufWriter := bufio.NewWriter(writer)
+	if err := binary.Write(bufWriter, binary.BigEndian, words); err != nil {
+		return err
+	}
+	return bufWriter.Flush()
+}
+
+// ihexRecordSize Intel HEX每条数据记录的字节数
+const ihexRecordSize = 16
+
+// WriteIntelHex 输出Intel HEX格式，32K的ROM正好占满16位的字节地址，不需要扩展地址记录
+func WriteIntelHex(writer io.Writer, words []uint16) error {
+	if len(words) > 0x8000 {
+		return fmt.Errorf("%d words exceed the 64K byte address space of Intel HEX", len(words))
+	}
+	data := make([]byte, len(words)*2)
+	for i, word := range words {
+		binary.BigEndian.PutUint16(data[i*2:], word)
+	}
+	bufWriter := bufio.NewWriter(writer)
+	for address := 0; address < len(data); address += ihexRecordSize {
+		end := address + ihexRecordSize
+		if end > len(data) {
+			end = len(data)
+		}
+		writeIhexRecord(bufWriter, uint16(address), 0x00, data[address:end])
+	}
+	writeIhexRecord(bufWriter, 0, 0x01, nil)
+	return bufWriter.Flush()
+}
+
+// writeIhexRecord 输出一条记录 :LLAAAATT[DD...]CC，校验和为前面所有字节之和的补码
+func writeIhexRecord(writer *bufio.Writer, address uint16, recordType byte, data []byte) {
+	sum := byte(len(data)) + byte(address>>8) + byte(address) + recordType
+	writer.WriteString(fmt.Sprintf(":%02X%04X%02X", len(data), address, recordType))
+	for _, b := range data {
+		writer.WriteString(fmt.Sprintf("%02X", b))
+		sum += b
+	}
+	writer.WriteString(fmt.Sprintf("%02X\n", -sum))
+}
+
+// WriteMemh 每行一个4位十六进制数
+func WriteMemh(writer io.Writer, words []uint16) error {
+	bufWriter := bufio.NewWriter(writer)
+	for _, word := range words {
+		bufWriter.WriteString(fmt.Sprintf("%04x\n", word))
+	}
+	return bufWriter.Flush()
+}
+
+// logisimWordsPerLine Logisim镜像每行输出的字数
+const logisimWordsPerLine = 8
+
+// WriteLogisim 输出Logisim的ROM镜像，以"v2.0 raw"开头，之后是以空白分隔的十六进制数
+func WriteLogisim(writer io.Writer, words []uint16) error {
+	bufWriter := bufio.NewWriter(writer)
+	bufWriter.WriteString("v2.0 raw\n")
+	for i, word := range words {
+		bufWriter.WriteString(fmt.Sprintf("%x", word))
+		if (i+1)%logisimWordsPerLine == 0 || i == len(words)-1 {
+			bufWriter.WriteString("\n")
+		} else {
+			bufWriter.WriteString(" ")
+		}
+	}
+	return bufWriter.Flush()
+}
@@ -0,0 +1,89 @@
+package hackasm
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// addWords Add.asm的机器码：@2 D=A @3 D=D+A @0 M=D
+var addWords = []uint16{0x0002, 0xec10, 0x0003, 0xe090, 0x0000, 0xe308}
+
+func writeFormat(t *testing.T, words []uint16, format OutputFormat) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteWords(&buf, words, format); err != nil {
+		t.Fatalf("%s: write err: %v", format, err)
+	}
+	return buf.String()
+}
+
+func TestWriteFormats(t *testing.T) {
+	// 再加三个字，Intel HEX需要两条数据记录，Logisim需要两行
+	words := append(append([]uint16(nil), addWords...), 0x7fff, 0xffff, 0x1234)
+	tests := []struct {
+		format OutputFormat
+		words  []uint16
+		expect string
+	}{
+		{FORMAT_HACK, addWords, "0000000000000010\n1110110000010000\n0000000000000011\n1110000010010000\n0000000000000000\n1110001100001000\n"},
+		{FORMAT_BIN, addWords, "\x00\x02\xec\x10\x00\x03\xe0\x90\x00\x00\xe3\x08"},
+		// 0C+00+00+00+0002EC100003E0900000E308各字节之和为0x368，校验和为0x100-0x68=0x98
+		{FORMAT_IHEX, addWords, ":0C0000000002EC100003E0900000E30898\n:00000001FF\n"},
+		// 第一条记录各字节之和0x6E8，校验和0x18；第二条10+00+10+00+12+34=0x58，校验和0xA8
+		{FORMAT_IHEX, words, ":100000000002EC100003E0900000E3087FFFFFFF18\n:020010001234A8\n:00000001FF\n"},
+		{FORMAT_MEMH, addWords, "0002\nec10\n0003\ne090\n0000\ne308\n"},
+		{FORMAT_LOGISIM, words, "v2.0 raw\n2 ec10 3 e090 0 e308 7fff ffff\n1234\n"},
+		{FORMAT_IHEX, nil, ":00000001FF\n"},
+		{FORMAT_LOGISIM, nil, "v2.0 raw\n"},
+	}
+	for _, test := range tests {
+		if got := writeFormat(t, test.words, test.format); got != test.expect {
+			t.Errorf("%s of %d words: expect\n%q\ngot\n%q", test.format, len(test.words), test.expect, got)
+		}
+	}
+}
+
+// TestIntelHexChecksum 与Intel HEX规范中的示例记录比较，并检查每条记录所有字节之和为0
+func TestIntelHexChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	writer := bufio.NewWriter(&buf)
+	writeIhexRecord(writer, 0x0010, 0x00, []byte("address gap"))
+	writer.Flush()
+	if expect := ":0B0010006164647265737320676170A7\n"; buf.String() != expect {
+		t.Errorf("expect %q, got %q", expect, buf.String())
+	}
+
+	words := make([]uint16, 1000)
+	for i := range words {
+		words[i] = uint16(i * 7919)
+	}
+	for _, record := range strings.Split(strings.TrimSpace(writeFormat(t, words, FORMAT_IHEX)), "\n") {
+		var sum byte
+		for i := 1; i+1 < len(record); i += 2 {
+			var b byte
+			for _, c := range record[i : i+2] {
+				b = b<<4 | byte(strings.IndexRune("0123456789ABCDEF", c))
+			}
+			sum += b
+		}
+		if sum != 0 {
+			t.Fatalf("%s: bytes sum to %02X instead of 0", record, sum)
+		}
+	}
+}
+
+func TestWriteFormatErrors(t *testing.T) {
+	if err := WriteIntelHex(&bytes.Buffer{}, make([]uint16, 0x8001)); err == nil {
+		t.Errorf("expect an error for more than 64K bytes of Intel HEX")
+	}
+	if _, err := ParseOutputFormat("srec"); err == nil {
+		t.Errorf("expect an error for an unknown format")
+	}
+	for _, format := range OutputFormats {
+		if parsed, err := ParseOutputFormat(string(format)); err != nil || parsed != format {
+			t.Errorf("%s: expect the format back, got %s, %v", format, parsed, err)
+		}
+	}
+}
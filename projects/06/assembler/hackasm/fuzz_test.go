@@ -0,0 +1,43 @@
+package hackasm
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzParserAdvance 任意输入都不能使Parser.Advance崩溃，出错时命令类型必须是ERR_COMMAND
+func FuzzParserAdvance(f *testing.F) {
+	for _, seed := range []string{
+		"@21", "@i", "@-1", "@'A'", "(LOOP)", "(LOOP", "()", "D=M", "AMD=D|A;JMP",
+		"D = M ; JGT // comment", "=;", ";JMP", "D=M;;", "@ @", "'", "'\\n'", "\t\r\n",
+		"#define X 1", "@99999999999999999999", "D=M<<", "(A)(B)", "M=\x00",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		parser := NewParser(strings.NewReader(source))
+		for parser.HasMoreCommands() {
+			err := parser.Advance()
+			commandType := parser.CommandType()
+			if err != nil {
+				if commandType != ERR_COMMAND {
+					t.Fatalf("%q: error %v with command type %d", source, err, commandType)
+				}
+				continue
+			}
+			switch commandType {
+			case A_COMMAND, L_COMMAND:
+				if parser.Symbol() == "" {
+					t.Fatalf("%q: empty symbol", source)
+				}
+			case C_COMMAND:
+				if parser.Comp() == "" {
+					t.Fatalf("%q: empty comp", source)
+				}
+			case ERR_COMMAND:
+				t.Fatalf("%q: ERR_COMMAND without an error", source)
+			}
+		}
+		Assemble(strings.NewReader(source))
+	})
+}
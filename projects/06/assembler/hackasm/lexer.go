@@ -0,0 +1,93 @@
+package hackasm
+
+// TokenKind 词法单元的种类
+type TokenKind int32
+
+const (
+	TOKEN_AT        TokenKind = 1 // @
+	TOKEN_LPAREN    TokenKind = 2 // (
+	TOKEN_RPAREN    TokenKind = 3 // )
+	TOKEN_EQUAL     TokenKind = 4 // =
+	TOKEN_SEMICOLON TokenKind = 5 // ;
+	// TOKEN_WORD 符号、常量或者comp/dest/jump中的一段，如D+1、'A'、D<<
+	TOKEN_WORD TokenKind = 6
+)
+
+// Token 一个词法单元，Col为其在行中的列号，从1开始
+type Token struct {
+	Kind TokenKind
+	Text string
+	Col  int
+}
+
+// Lexer 将一行汇编代码切分为词法单元，跳过空白以及 // 之后的注释
+type Lexer struct {
+	line string
+	pos  int
+	// Comment 是否遇到了注释
+	Comment bool
+}
+
+func NewLexer(line string) *Lexer {
+	return &Lexer{line: line}
+}
+
+// Tokens 返回整行的词法单元
+func (l *Lexer) Tokens() []Token {
+	var tokens []Token
+	for {
+		token, ok := l.Next()
+		if !ok {
+			return tokens
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// Next 返回下一个词法单元，行尾或注释处返回false
+func (l *Lexer) Next() (Token, bool) {
+	for l.pos < len(l.line) && isSpace(l.line[l.pos]) {
+		l.pos += 1
+	}
+	if l.pos >= len(l.line) {
+		return Token{}, false
+	}
+	start := l.pos
+	if l.isComment(start) {
+		l.Comment = true
+		l.pos = len(l.line)
+		return Token{}, false
+	}
+	if kind, ok := punctuations[l.line[start]]; ok {
+		l.pos += 1
+		return Token{Kind: kind, Text: l.line[start:l.pos], Col: start + 1}, true
+	}
+	// 字符常量中可以出现空白和标点，如 ' ' 和 '('
+	if l.line[start] == '\'' && start+2 < len(l.line) && l.line[start+2] == '\'' {
+		l.pos += 3
+		return Token{Kind: TOKEN_WORD, Text: l.line[start:l.pos], Col: start + 1}, true
+	}
+	for l.pos < len(l.line) && !isSpace(l.line[l.pos]) && !l.isComment(l.pos) {
+		if _, ok := punctuations[l.line[l.pos]]; ok {
+			break
+		}
+		l.pos += 1
+	}
+	return Token{Kind: TOKEN_WORD, Text: l.line[start:l.pos], Col: start + 1}, true
+}
+
+var punctuations = map[byte]TokenKind{
+	'@': TOKEN_AT,
+	'(': TOKEN_LPAREN,
+	')': TOKEN_RPAREN,
+	'=': TOKEN_EQUAL,
+	';': TOKEN_SEMICOLON,
+}
+
+func (l *Lexer) isComment(pos int) bool {
+	return pos+1 < len(l.line) && l.line[pos] == '/' && l.line[pos+1] == '/'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r'
+}
@@ -0,0 +1,57 @@
+package hackasm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// tokenText 以 "文本@列号" 的形式列出词法单元，便于比较
+func tokenText(tokens []Token) string {
+	texts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		texts = append(texts, fmt.Sprintf("%s@%d", token.Text, token.Col))
+	}
+	return strings.Join(texts, " ")
+}
+
+func TestLexer(t *testing.T) {
+	tests := []struct {
+		line    string
+		expect  string
+		comment bool
+	}{
+		{"D=M;JGT", "D@1 =@2 M@3 ;@4 JGT@5", false},
+		{"D = M ; JGT", "D@1 =@3 M@5 ;@7 JGT@9", false},
+		{"\t AM = M-1", "AM@3 =@6 M-1@8", false},
+		{"  @i // counter", "@@3 i@4", true},
+		{"@i// counter", "@@1 i@2", true},
+		{"(LOOP) // start", "(@1 LOOP@2 )@6", true},
+		{"( LOOP )", "(@1 LOOP@3 )@8", false},
+		{"0;JMP//forever", "0@1 ;@2 JMP@3", true},
+		{"D=D/M", "D@1 =@2 D/M@3", false},
+		{"@' '", "@@1 ' '@2", false},
+		{"@'/' // slash", "@@1 '/'@2", true},
+		{"@';'", "@@1 ';'@2", false},
+		{"D=D<< // shift", "D@1 =@2 D<<@3", true},
+		{"// only a comment", "", true},
+		{"   \r", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		lexer := NewLexer(test.line)
+		if got := tokenText(lexer.Tokens()); got != test.expect {
+			t.Errorf("%q: expect tokens %q, got %q", test.line, test.expect, got)
+		}
+		if lexer.Comment != test.comment {
+			t.Errorf("%q: expect Comment %v, got %v", test.line, test.comment, lexer.Comment)
+		}
+	}
+
+	kinds := []TokenKind{TOKEN_WORD, TOKEN_EQUAL, TOKEN_WORD, TOKEN_SEMICOLON, TOKEN_WORD, TOKEN_AT, TOKEN_LPAREN, TOKEN_RPAREN}
+	for i, token := range NewLexer("D = M ; JGT @ ( )").Tokens() {
+		if token.Kind != kinds[i] {
+			t.Errorf("token %d %q: expect kind %d, got %d", i, token.Text, kinds[i], token.Kind)
+		}
+	}
+}
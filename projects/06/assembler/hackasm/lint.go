@@ -0,0 +1,100 @@
+package hackasm
+
+import (
+	"strings"
+)
+
+// Lint 检查程序中常见的错误写法，以ErrorList返回警告，没有警告时返回nil：
+//
+//   - 同一条指令的comp读取M、dest同时写A和D，如AD=M、AMD=M+1，A=M和弹栈用的AM=M-1是正常用法
+//   - 跳转指令前的A指令不是标签
+//   - 定义了却没有被引用的标签
+//   - 只出现一次的变量，通常是标签名写错了
+//   - 程序结尾没有无限循环
+//   - 只有大小写不同的符号
+func Lint(program *Program) error {
+	var warnings ErrorList
+	table := NewSymbolTalbe()
+	collectLabels(program.Instructions, &table)
+
+	references := map[string]int{}
+	for _, ins := range program.Instructions {
+		if ins.Type == A_COMMAND && isSymbol(ins.Symbol) {
+			references[ins.Symbol] += 1
+		}
+	}
+	for _, symbol := range program.Exports {
+		references[symbol.Name] += 1
+	}
+
+	var last *Instruction
+	for i, ins := range program.Instructions {
+		switch ins.Type {
+		case C_COMMAND:
+			if strings.Contains(ins.Comp, "M") && strings.Contains(ins.Dest, "A") && strings.Contains(ins.Dest, "D") {
+				warnings = append(warnings, ins.errorf(ins.Dest, "comp reads M while dest writes both A and D"))
+			}
+			if ins.isJump() && i > 0 {
+				prev := program.Instructions[i-1]
+				if prev.Type == A_COMMAND && table.Kind(prev.Symbol) != LABEL {
+					warnings = append(warnings, prev.errorf(prev.Symbol, "jump target is not a label"))
+				}
+			}
+			last = &program.Instructions[i]
+		case A_COMMAND:
+			if isSymbol(ins.Symbol) && !table.Contains(ins.Symbol) && references[ins.Symbol] == 1 {
+				warnings = append(warnings, ins.errorf(ins.Symbol, "variable used only once, maybe a misspelled label"))
+			}
+			last = &program.Instructions[i]
+		case L_COMMAND:
+			if references[ins.Symbol] == 0 {
+				warnings = append(warnings, ins.errorf(ins.Symbol, "label defined but never referenced"))
+			}
+		}
+	}
+	if last != nil && !(last.isJump() && last.Jump == JMP) {
+		warnings = append(warnings, last.errorf("", "program does not end with an infinite loop, the CPU will run past the last instruction"))
+	}
+
+	spellings := map[string]string{}
+	definitions := map[string]Instruction{}
+	for symbol := range table.table {
+		if table.Kind(symbol) == PREDEFINED {
+			spellings[strings.ToUpper(symbol)] = symbol
+		}
+	}
+	for _, ins := range program.Instructions {
+		if ins.Type == L_COMMAND {
+			if _, ok := spellings[strings.ToUpper(ins.Symbol)]; !ok {
+				spellings[strings.ToUpper(ins.Symbol)] = ins.Symbol
+				definitions[ins.Symbol] = ins
+			}
+		}
+	}
+	for _, ins := range program.Instructions {
+		if ins.Type == A_COMMAND && isSymbol(ins.Symbol) {
+			key := strings.ToUpper(ins.Symbol)
+			if _, ok := spellings[key]; !ok {
+				spellings[key] = ins.Symbol
+				definitions[ins.Symbol] = ins
+			}
+		}
+	}
+	reported := map[string]bool{}
+	for _, ins := range program.Instructions {
+		if (ins.Type != A_COMMAND && ins.Type != L_COMMAND) || !isSymbol(ins.Symbol) {
+			continue
+		}
+		spelling := spellings[strings.ToUpper(ins.Symbol)]
+		if spelling == ins.Symbol || reported[ins.Symbol] {
+			continue
+		}
+		reported[ins.Symbol] = true
+		if first, ok := definitions[spelling]; ok {
+			warnings = append(warnings, ins.errorf(ins.Symbol, "symbol differs only in case from '%s' at %s", spelling, first.Pos))
+		} else {
+			warnings = append(warnings, ins.errorf(ins.Symbol, "symbol differs only in case from predefined '%s'", spelling))
+		}
+	}
+	return warnings.Err()
+}
@@ -0,0 +1,89 @@
+package hackasm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lintEnd 每个例子结尾的无限循环，本身没有警告
+const lintEnd = "\n(END)\n@END\n0;JMP"
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// line和msg为期望的唯一一个警告，msg为空表示没有警告
+		line int
+		msg  string
+	}{
+		{"comp reads M and dest writes A and D", "@SP\nAD=M" + lintEnd, 2, "comp reads M while dest writes both A and D"},
+		{"comp reads M and dest writes A, M and D", "@SP\nAMD=M+1" + lintEnd, 2, "comp reads M while dest writes both A and D"},
+		{"A=M alone is a pointer", "@SP\nA=M\nMD=M+1" + lintEnd, 0, ""},
+		{"AM=M-1 pops the stack", "@SP\nAM=M-1\nD=M" + lintEnd, 0, ""},
+		{"jump to a constant", "@5\nD;JGT" + lintEnd, 1, "jump target is not a label"},
+		{"jump to a predefined symbol", "@R0\n0;JMP" + lintEnd, 1, "jump target is not a label"},
+		{"jump to a label or a computed address", "(L)\n@L\nD;JGT\n@R15\nA=M\n0;JMP" + lintEnd, 0, ""},
+		{"label never referenced", "(UNUSED)\nD=0" + lintEnd, 1, "label defined but never referenced"},
+		{"exported label", "#export F\n(F)\nD=0" + lintEnd, 0, ""},
+		{"variable used once", "@count\nM=0" + lintEnd, 1, "variable used only once"},
+		{"variable used twice", "@count\nM=0\n@count\nM=M+1" + lintEnd, 0, ""},
+		{"no infinite loop at the end", "@R0\nM=0", 2, "does not end with an infinite loop"},
+		{"conditional jump at the end", "(L)\n@L\nD;JGT", 3, "does not end with an infinite loop"},
+		{"predefined symbol in another case", "@r0\nM=0\n@r0\nM=0" + lintEnd, 1, "differs only in case from predefined 'R0'"},
+		{"variable in another case", "@count\nM=0\n@count\nM=1\n@Count\nM=0\n@Count\nM=1" + lintEnd, 5, "differs only in case from 'count' at 1:1"},
+		{"different symbols", "(LOOP)\n@LOOP\n0;JMP\n@LOOP2\n0;JMP\n(LOOP2)\n@LOOP\n0;JMP", 0, ""},
+	}
+	for _, test := range tests {
+		program, err := Parse("", strings.NewReader(test.source))
+		if err != nil {
+			t.Fatalf("%s: parse err: %v", test.name, err)
+		}
+		err = Lint(program)
+		if test.msg == "" {
+			if err != nil {
+				t.Errorf("%s: expect no warnings, got %v", test.name, err)
+			}
+			continue
+		}
+		warnings, ok := err.(ErrorList)
+		if !ok || len(warnings) != 1 || warnings[0].Line != test.line || !strings.Contains(warnings[0].Msg, test.msg) {
+			t.Errorf("%s: expect %q on line %d, got %v", test.name, test.msg, test.line, err)
+		}
+	}
+}
+
+// TestLintTranslatedCode 翻译器生成的代码经-O优化后用AM=M-1弹栈，不能被当作错误
+func TestLintTranslatedCode(t *testing.T) {
+	paths, err := filepath.Glob("testdata/vm/*.asm")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no translated programs in testdata/vm: %v", err)
+	}
+	pops := 0
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, program, err := AssembleFile(path, f, Options{Optimize: true})
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: assemble err: %v", path, err)
+		}
+		for _, ins := range program.Instructions {
+			if ins.Type == C_COMMAND && ins.Dest == "AM" && ins.Comp == "M-1" {
+				pops += 1
+			}
+		}
+		warnings, _ := Lint(program).(ErrorList)
+		for _, warning := range warnings {
+			if strings.Contains(warning.Msg, "comp reads M") {
+				t.Errorf("%s: unexpected warning %v", path, warning)
+			}
+		}
+	}
+	if pops == 0 {
+		t.Errorf("expect the optimized programs to pop with AM=M-1")
+	}
+}
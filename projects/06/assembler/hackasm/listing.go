@@ -0,0 +1,38 @@
+package hackasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteSymbols 输出符号表，每行 "符号 地址 类型"，可以被ReadSymbolMap读取
+func WriteSymbols(writer io.Writer, table *SymbolTable) error {
+	bufWriter := bufio.NewWriter(writer)
+	for _, symbol := range table.Symbols() {
+		bufWriter.WriteString(fmt.Sprintf("%-24s %5d %s\n", symbol, table.GetAddress(symbol), table.Kind(symbol)))
+	}
+	return bufWriter.Flush()
+}
+
+// ListingLine 列表文件中的一行源码，Address为-1表示该行没有生成指令
+type ListingLine struct {
+	Address int
+	Word    uint16
+	Source  string
+}
+
+// WriteListing 输出列表文件，每行依次为ROM地址、二进制机器码、十六进制机器码和源码
+func WriteListing(writer io.Writer, lines []ListingLine) error {
+	bufWriter := bufio.NewWriter(writer)
+	bufWriter.WriteString(fmt.Sprintf("%-5s  %-16s  %-4s  %s\n", "ROM", "BINARY", "HEX", "SOURCE"))
+	for _, line := range lines {
+		if line.Address < 0 {
+			bufWriter.WriteString(strings.TrimRight(fmt.Sprintf("%-5s  %-16s  %-4s  %s", "", "", "", line.Source), " \t") + "\n")
+			continue
+		}
+		bufWriter.WriteString(strings.TrimRight(fmt.Sprintf("%05d  %016b  %04X  %s", line.Address, line.Word, line.Word, line.Source), " \t") + "\n")
+	}
+	return bufWriter.Flush()
+}
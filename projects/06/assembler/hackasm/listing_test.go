@@ -0,0 +1,69 @@
+package hackasm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteListing(t *testing.T) {
+	source := strings.Join([]string{
+		"#define N 5",
+		"// count down",
+		"@N",
+		"D=A",
+		"(LOOP)",
+		"PUSH_D",
+		"GOTO LOOP",
+	}, "\n")
+	words, _, program, err := AssembleFile("loop.asm", strings.NewReader(source), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := WriteListing(&out, program.Listing(words)); err != nil {
+		t.Fatal(err)
+	}
+	// 显示原始的源码：@N而不是@5，宏调用只显示一次
+	expect := strings.Join([]string{
+		"ROM    BINARY            HEX   SOURCE",
+		"                               #define N 5",
+		"                               // count down",
+		"00000  0000000000000101  0005  @N",
+		"00001  1110110000010000  EC10  D=A",
+		"                               (LOOP)",
+		"00002  0000000000000000  0000  PUSH_D",
+		"00003  1111110000100000  FC20",
+		"00004  1110001100001000  E308",
+		"00005  0000000000000000  0000",
+		"00006  1111110111001000  FDC8",
+		"00007  0000000000000010  0002  GOTO LOOP",
+		"00008  1110101010000111  EA87",
+		"",
+	}, "\n")
+	if out.String() != expect {
+		t.Errorf("expect\n%s\ngot\n%s", expect, out.String())
+	}
+}
+
+func TestWriteSymbols(t *testing.T) {
+	words, table, err := Assemble(strings.NewReader("@i\nM=1\n(LOOP)\n@LOOP\n0;JMP"))
+	if err != nil || len(words) != 4 {
+		t.Fatalf("assemble err: %v", err)
+	}
+	var out strings.Builder
+	if err := WriteSymbols(&out, table); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	// 预定义符号在前，然后是标签和变量
+	if len(lines) < 2 || lines[len(lines)-2] != "LOOP                         2 label" || lines[len(lines)-1] != "i                           16 variable" {
+		t.Fatalf("expect LOOP and i at the end, got\n%s", out.String())
+	}
+	symbols, err := ReadSymbolMap("test.sym", strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("read the written symbols err: %v", err)
+	}
+	if symbols.labels[2] != "LOOP" || symbols.variables[16] != "i" {
+		t.Errorf("expect LOOP at ROM 2 and i at RAM 16, got %v %v", symbols.labels, symbols.variables)
+	}
+}
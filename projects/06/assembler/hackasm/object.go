@@ -0,0 +1,304 @@
+package hackasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RelocKind 目标文件中一个字在链接时的处理方式
+type RelocKind string
+
+const (
+	// RELOC_NONE 常量、预定义符号以及C指令，链接时不变
+	RELOC_NONE RelocKind = ""
+	// RELOC_LABEL 本文件的标签，Word为相对本文件开头的地址，链接时加上本文件的起始地址
+	RELOC_LABEL RelocKind = "label"
+	// RELOC_EXTERN #import的符号，链接时替换为导出该符号的文件中的地址
+	RELOC_EXTERN RelocKind = "extern"
+	// RELOC_VARIABLE 变量，链接时在所有目标文件之间按名字统一分配RAM地址
+	RELOC_VARIABLE RelocKind = "var"
+)
+
+// ObjectWord 目标文件中的一个字
+type ObjectWord struct {
+	Word   uint16
+	Reloc  RelocKind
+	Symbol string
+	// Line 引用符号（extern和var）的指令在源文件中的行号，用于报告链接错误
+	Line int
+}
+
+// Object 可重定位的目标文件，标签默认只在本文件内可见，#export的标签可以被其他文件#import
+type Object struct {
+	// Source 生成目标文件的源文件
+	Source  string
+	Words   []ObjectWord
+	Exports map[string]int
+	Imports []string
+}
+
+// AssembleObject 将filename汇编为目标文件
+func AssembleObject(filename string, reader io.Reader, options Options) (*Object, error) {
+	program, errs := parse(filename, reader)
+	if options.Optimize && len(errs) == 0 {
+		stats, err := program.Optimize()
+		if err != nil {
+			return nil, err
+		}
+		program.Optimization = &stats
+	}
+	object, err := NewEncoder(options).EncodeObject(filename, program)
+	if encodeErrs, ok := err.(ErrorList); ok {
+		errs = append(errs, encodeErrs...)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// EncodeObject 翻译program的指令，标签、#import的符号和变量留给链接器处理
+func (e *Encoder) EncodeObject(filename string, program *Program) (*Object, error) {
+	table := NewSymbolTalbe()
+	codeAddress, errs := collectLabels(program.Instructions, &table)
+	object := &Object{
+		Source:  filename,
+		Words:   make([]ObjectWord, 0, codeAddress),
+		Exports: map[string]int{},
+	}
+	for _, symbol := range program.Exports {
+		if table.Kind(symbol.Name) != LABEL {
+			errs = append(errs, symbol.errorf("exported symbol is not a label defined in this file"))
+			continue
+		}
+		object.Exports[symbol.Name] = table.GetAddress(symbol.Name)
+	}
+	imports := map[string]bool{}
+	for _, symbol := range program.Imports {
+		if table.Contains(symbol.Name) {
+			errs = append(errs, symbol.errorf("imported symbol is already defined in this file"))
+			continue
+		}
+		if !imports[symbol.Name] {
+			imports[symbol.Name] = true
+			object.Imports = append(object.Imports, symbol.Name)
+		}
+	}
+
+	for _, ins := range program.Instructions {
+		if ins.Type == L_COMMAND {
+			continue
+		}
+		var word ObjectWord
+		switch {
+		case ins.Type == A_COMMAND && imports[ins.Symbol]:
+			word.Reloc, word.Symbol, word.Line = RELOC_EXTERN, ins.Symbol, ins.Pos.Line
+		case ins.Type == A_COMMAND && table.Kind(ins.Symbol) == LABEL:
+			word.Reloc, word.Word = RELOC_LABEL, uint16(table.GetAddress(ins.Symbol))
+		case ins.Type == A_COMMAND && isSymbol(ins.Symbol) && !table.Contains(ins.Symbol):
+			word.Reloc, word.Symbol, word.Line = RELOC_VARIABLE, ins.Symbol, ins.Pos.Line
+		default:
+			value, err := e.EncodeInstruction(ins, &table)
+			if err != nil {
+				errs = append(errs, err...)
+				continue
+			}
+			word.Word = value
+		}
+		object.Words = append(object.Words, word)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s LinkSymbol) errorf(format string, args ...interface{}) *AsmError {
+	return &AsmError{
+		File: s.Pos.File,
+		Line: s.Pos.Line,
+		Col:  s.Pos.Col,
+		Text: s.Name,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// WriteObject 以文本格式输出目标文件：
+//
+//	source Main.asm
+//	export NAME ADDRESS
+//	import NAME
+//	0000000000010000        不需要重定位的字
+//	label ADDRESS           本文件的标签
+//	extern NAME LINE        #import的符号
+//	var NAME LINE           变量
+func WriteObject(writer io.Writer, object *Object) error {
+	bufWriter := bufio.NewWriter(writer)
+	bufWriter.WriteString("// Hack relocatable object\n")
+	bufWriter.WriteString(fmt.Sprintf("source %s\n", object.Source))
+	for _, name := range sortedExports(object) {
+		bufWriter.WriteString(fmt.Sprintf("export %s %d\n", name, object.Exports[name]))
+	}
+	for _, name := range object.Imports {
+		bufWriter.WriteString(fmt.Sprintf("import %s\n", name))
+	}
+	for _, word := range object.Words {
+		switch word.Reloc {
+		case RELOC_NONE:
+			bufWriter.WriteString(fmt.Sprintf("%016b\n", word.Word))
+		case RELOC_LABEL:
+			bufWriter.WriteString(fmt.Sprintf("label %d\n", word.Word))
+		default:
+			bufWriter.WriteString(fmt.Sprintf("%s %s %d\n", word.Reloc, word.Symbol, word.Line))
+		}
+	}
+	return bufWriter.Flush()
+}
+
+// ReadObject 读取WriteObject输出的目标文件
+func ReadObject(filename string, reader io.Reader) (*Object, error) {
+	object := &Object{Source: filename, Exports: map[string]int{}}
+	var errs ErrorList
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo += 1
+		line, _ := splitComment(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		errorf := func(format string, args ...interface{}) {
+			errs = append(errs, &AsmError{File: filename, Line: lineNo, Col: 1, Text: line, Msg: fmt.Sprintf(format, args...)})
+		}
+		// number ROM地址，lineNumber 源码的行号，长的源码可以超过ROM的大小
+		number := func(s string) int {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 || n >= ROMSize {
+				errorf("invalid number '%s'", s)
+			}
+			return n
+		}
+		lineNumber := func(s string) int {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				errorf("invalid line number '%s'", s)
+			}
+			return n
+		}
+		switch {
+		case fields[0] == "source" && len(fields) == 2:
+			object.Source = fields[1]
+		case fields[0] == "export" && len(fields) == 3:
+			object.Exports[fields[1]] = number(fields[2])
+		case fields[0] == "import" && len(fields) == 2:
+			object.Imports = append(object.Imports, fields[1])
+		case fields[0] == string(RELOC_LABEL) && len(fields) == 2:
+			object.Words = append(object.Words, ObjectWord{Word: uint16(number(fields[1])), Reloc: RELOC_LABEL})
+		case (fields[0] == string(RELOC_EXTERN) || fields[0] == string(RELOC_VARIABLE)) && len(fields) == 3:
+			object.Words = append(object.Words, ObjectWord{Reloc: RelocKind(fields[0]), Symbol: fields[1], Line: lineNumber(fields[2])})
+		case len(fields) == 1 && len(fields[0]) == 16:
+			word, err := strconv.ParseUint(fields[0], 2, 16)
+			if err != nil {
+				errorf("invalid machine code")
+				continue
+			}
+			object.Words = append(object.Words, ObjectWord{Word: uint16(word)})
+		default:
+			errorf("invalid object line")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// Link 按顺序排列目标文件并解析它们之间的符号，变量从VariableBase开始统一分配。
+// 未解析的#import、重复导出的符号以及ROM和变量的溢出都作为错误返回
+func Link(objects []*Object, options Options) ([]uint16, *SymbolTable, error) {
+	base, limit, err := options.variableWindow()
+	if err != nil {
+		return nil, nil, err
+	}
+	var errs ErrorList
+	table := NewSymbolTalbe()
+	table.SetVariableBase(base)
+
+	bases := make([]int, len(objects))
+	exporters := map[string]*Object{}
+	codeAddress := 0
+	for i, object := range objects {
+		bases[i] = codeAddress
+		codeAddress += len(object.Words)
+		for _, name := range sortedExports(object) {
+			if other, ok := exporters[name]; ok {
+				errs = append(errs, &AsmError{File: object.Source, Text: name, Msg: fmt.Sprintf("duplicate symbol, also exported by %s", other.Source)})
+				continue
+			}
+			if table.Contains(name) {
+				errs = append(errs, &AsmError{File: object.Source, Text: name, Msg: "exported symbol redefines predefined symbol"})
+				continue
+			}
+			exporters[name] = object
+			table.AddEntry(name, bases[i]+object.Exports[name])
+		}
+	}
+	if codeAddress > ROMSize {
+		errs = append(errs, &AsmError{Msg: fmt.Sprintf("ROM overflow, linked program has %d instructions, more than %d", codeAddress, ROMSize)})
+	}
+
+	words := make([]uint16, 0, codeAddress)
+	for i, object := range objects {
+		for _, word := range object.Words {
+			value := word.Word
+			switch word.Reloc {
+			case RELOC_LABEL:
+				value += uint16(bases[i])
+			case RELOC_EXTERN:
+				if _, ok := exporters[word.Symbol]; !ok {
+					errs = append(errs, &AsmError{File: object.Source, Line: word.Line, Col: 1, Text: word.Symbol, Msg: "unresolved symbol"})
+					continue
+				}
+				value = uint16(table.GetAddress(word.Symbol))
+			case RELOC_VARIABLE:
+				if exporter, ok := exporters[word.Symbol]; ok {
+					errs = append(errs, &AsmError{File: object.Source, Line: word.Line, Col: 1, Text: word.Symbol,
+						Msg: fmt.Sprintf("symbol is exported by %s, #import it instead of using it as a variable", exporter.Source)})
+					continue
+				}
+				if !table.Contains(word.Symbol) {
+					if table.NextVariableAddress() == limit {
+						errs = append(errs, &AsmError{File: object.Source, Line: word.Line, Col: 1, Text: word.Symbol,
+							Msg: fmt.Sprintf("variable overflow, no free RAM left in %d..%d for more than %d variables", base, limit-1, limit-base)})
+					}
+					table.AddVariable(word.Symbol)
+				}
+				value = uint16(table.GetAddress(word.Symbol))
+			}
+			words = append(words, value)
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, nil, err
+	}
+	return words, &table, nil
+}
+
+func sortedExports(object *Object) []string {
+	names := make([]string, 0, len(object.Exports))
+	for name := range object.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
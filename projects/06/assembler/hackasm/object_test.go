@@ -0,0 +1,211 @@
+package hackasm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var linkSources = []struct {
+	name   string
+	source string
+}{
+	{"main.asm", "#import ADD\n@ADD\n0;JMP\n(END)\n@END\n0;JMP\n@tmp"},
+	{"math.asm", "#export ADD\n(ADD)\n@tmp\nM=D\n(LOOP)\n@LOOP\n0;JMP"},
+	{"util.asm", "@count\nM=0\n@tmp\n@R0"},
+}
+
+func assembleObjects(t *testing.T, sources ...string) []*Object {
+	t.Helper()
+	objects := make([]*Object, 0, len(sources)/2)
+	for i := 0; i < len(sources); i += 2 {
+		object, err := AssembleObject(sources[i], strings.NewReader(sources[i+1]), Options{})
+		if err != nil {
+			t.Fatalf("%s: assemble object err: %v", sources[i], err)
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+func linkObjects(t *testing.T) []*Object {
+	t.Helper()
+	var sources []string
+	for _, s := range linkSources {
+		sources = append(sources, s.name, s.source)
+	}
+	return assembleObjects(t, sources...)
+}
+
+func TestLink(t *testing.T) {
+	objects := linkObjects(t)
+	words, table, err := Link(objects, Options{})
+	if err != nil {
+		t.Fatalf("link err: %v", err)
+	}
+	// main占0..4，math占5..8，util占9..12
+	expect := map[int]uint16{
+		0:  5,  // @ADD，math中的第0条
+		2:  2,  // @END
+		4:  16, // @tmp，第一个变量
+		5:  16, // math中的@tmp
+		7:  7,  // math中的@LOOP，5+2
+		9:  17, // @count
+		11: 16, // util中的@tmp
+		12: 0,  // @R0
+	}
+	if len(words) != 13 {
+		t.Fatalf("expect 13 words, got %d", len(words))
+	}
+	for address, word := range expect {
+		if words[address] != word {
+			t.Errorf("ROM[%d]: expect %d, got %d", address, word, words[address])
+		}
+	}
+	if table.GetAddress("ADD") != 5 || table.Kind("count") != VARIABLE {
+		t.Errorf("expect ADD at 5 and count as a variable in the symbol table")
+	}
+
+	// 与把各个文件拼在一起汇编的结果相同
+	var whole strings.Builder
+	for _, s := range linkSources {
+		for _, line := range strings.Split(s.source, "\n") {
+			if !strings.HasPrefix(line, "#") {
+				whole.WriteString(line + "\n")
+			}
+		}
+	}
+	single, _, err := Assemble(strings.NewReader(whole.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(words, single) {
+		t.Errorf("expect the words of the concatenated source\n%v\ngot\n%v", single, words)
+	}
+}
+
+func TestLinkErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		options Options
+		file    string
+		line    int
+		msg     string
+	}{
+		{
+			"unresolved import",
+			[]string{"a.asm", "#import F\n@R0\n@F\n0;JMP"},
+			Options{}, "a.asm", 3, "unresolved symbol",
+		},
+		{
+			"duplicate export",
+			[]string{"a.asm", "#export F\n(F)\n@F", "b.asm", "#export F\n(F)\n@F"},
+			Options{}, "b.asm", 0, "duplicate symbol, also exported by a.asm",
+		},
+		{
+			"exported symbol used as a variable",
+			[]string{"a.asm", "#export F\n(F)\n@F", "b.asm", "@R0\n@F"},
+			Options{}, "b.asm", 2, "#import it instead",
+		},
+		{
+			"variables of all objects share the window",
+			[]string{"a.asm", "@x\n@y", "b.asm", "@y\n@z"},
+			Options{VariableBase: 16, VariableLimit: 18}, "b.asm", 2, "variable overflow",
+		},
+	}
+	for _, test := range tests {
+		_, _, err := Link(assembleObjects(t, test.sources...), test.options)
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 {
+			t.Errorf("%s: expect one error, got %v", test.name, err)
+			continue
+		}
+		if errs[0].File != test.file || errs[0].Line != test.line || !strings.Contains(errs[0].Msg, test.msg) {
+			t.Errorf("%s: expect %q at %s:%d, got %v", test.name, test.msg, test.file, test.line, errs[0])
+		}
+	}
+}
+
+func TestAssembleObjectErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		line   int
+		msg    string
+	}{
+		{"#export F\n@F", 1, "not a label defined in this file"},
+		{"#export R0", 1, "not a label defined in this file"},
+		{"(F)\n#import F\n@F", 2, "already defined in this file"},
+	}
+	for _, test := range tests {
+		_, err := AssembleObject("a.asm", strings.NewReader(test.source), Options{})
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 || errs[0].Line != test.line || !strings.Contains(errs[0].Msg, test.msg) {
+			t.Errorf("%q: expect %q on line %d, got %v", test.source, test.msg, test.line, err)
+		}
+	}
+	// 没有链接时#import的符号无法解析
+	if _, _, err := Assemble(strings.NewReader("#import F\n@F")); err == nil || !strings.Contains(err.Error(), "needs linking") {
+		t.Errorf("expect an error for an import without linking, got %v", err)
+	}
+}
+
+func TestObjectRoundTrip(t *testing.T) {
+	for _, object := range linkObjects(t) {
+		var out strings.Builder
+		if err := WriteObject(&out, object); err != nil {
+			t.Fatal(err)
+		}
+		again, err := ReadObject("other.o", strings.NewReader(out.String()))
+		if err != nil {
+			t.Fatalf("%s: read the written object err: %v\n%s", object.Source, err, out.String())
+		}
+		if !reflect.DeepEqual(object, again) {
+			t.Errorf("%s: expect the same object after the round trip\n%+v\ngot\n%+v", object.Source, object, again)
+		}
+	}
+
+	text := "source main.asm\nexport ADD 3\nimport SUB\n0000000000000101\nlabel 2\nextern SUB 7\nvar tmp 8\n"
+	object, err := ReadObject("main.o", strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Object{
+		Source:  "main.asm",
+		Exports: map[string]int{"ADD": 3},
+		Imports: []string{"SUB"},
+		Words: []ObjectWord{
+			{Word: 5},
+			{Word: 2, Reloc: RELOC_LABEL},
+			{Reloc: RELOC_EXTERN, Symbol: "SUB", Line: 7},
+			{Reloc: RELOC_VARIABLE, Symbol: "tmp", Line: 8},
+		},
+	}
+	if !reflect.DeepEqual(object, expect) {
+		t.Errorf("expect\n%+v\ngot\n%+v", expect, object)
+	}
+
+	// 超过32767行的源码中的符号引用
+	long, err := AssembleObject("long.asm", strings.NewReader(strings.Repeat("\n", 40000)+"#import F\n@F\n0;JMP\n@tmp\nM=0"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := WriteObject(&out, long); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadObject("long.o", strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("read the object of a long source err: %v", err)
+	}
+	if !reflect.DeepEqual(long, again) || again.Words[0].Line != 40002 || again.Words[2].Line != 40004 {
+		t.Errorf("expect the lines 40002 and 40004 after the round trip, got %+v", again.Words)
+	}
+
+	for _, line := range []string{"label x", "label 40000", "0000000000000002", "000000000000000x", "var tmp", "var tmp -1", "extern SUB x",
+		"export ADD 40000", "section text"} {
+		if _, err := ReadObject("bad.o", strings.NewReader(line)); err == nil {
+			t.Errorf("%q: expect an error", line)
+		}
+	}
+}
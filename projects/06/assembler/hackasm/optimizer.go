@@ -0,0 +1,338 @@
+package hackasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OptimizeStats 窥孔优化的统计，各项为对应规则删除的指令数
+type OptimizeStats struct {
+	Before int
+	After  int
+	// Reloads A中已经是该值的@X，以及结果没有被使用就被覆盖的A
+	Reloads int
+	// StackOps 弹栈后立刻压栈、压栈后立刻弹栈时多余的SP修改
+	StackOps int
+	// Unreachable 无条件跳转之后、下一个标签之前的指令
+	Unreachable int
+	// Jumps 跳到紧随其后的标签的跳转
+	Jumps int
+}
+
+func (s OptimizeStats) String() string {
+	saved := 0.0
+	if s.Before > 0 {
+		saved = float64(s.Before-s.After) * 100 / float64(s.Before)
+	}
+	return fmt.Sprintf("instructions: %d -> %d (-%.1f%%), reloads: -%d, stack: -%d, unreachable: -%d, jumps: -%d",
+		s.Before, s.After, saved, s.Reloads, s.StackOps, s.Unreachable, s.Jumps)
+}
+
+// optItem 优化中的一条指令，index为其在优化前Program.Instructions中的下标
+type optItem struct {
+	Instruction
+	index int
+}
+
+// Optimize 对程序做窥孔优化，直到没有可以删除的指令为止，同时更新Lines中的指令下标。
+// 优化会改变指令的ROM地址，跳到常量地址的@N会改为跳到插入在原来第N条指令之前的标签。
+// 跳转指令或者不跳转时随后的指令用到了A中的N，或者N在程序之外时返回错误。
+// 先存入RAM或D再跳转的常量地址无法识别，这样的程序不能优化
+func (p *Program) Optimize() (OptimizeStats, error) {
+	items, err := labelConstantJumps(p.Instructions)
+	if err != nil {
+		return OptimizeStats{}, err
+	}
+	stats := OptimizeStats{Before: countCode(items)}
+	for {
+		n := len(items)
+		items = removeUnreachable(items, &stats)
+		items = removeNextJumps(items, &stats)
+		items = collapseStackOps(items, &stats)
+		items = removeReloads(items, &stats)
+		if len(items) == n {
+			break
+		}
+	}
+	stats.After = countCode(items)
+
+	newIndex := make(map[int]int, len(items))
+	p.Instructions = make([]Instruction, 0, len(items))
+	for i, item := range items {
+		if item.index >= 0 {
+			newIndex[item.index] = i
+		}
+		p.Instructions = append(p.Instructions, item.Instruction)
+	}
+	for i, line := range p.Lines {
+		if line.Instruction < 0 {
+			continue
+		}
+		if index, ok := newIndex[line.Instruction]; ok {
+			p.Lines[i].Instruction = index
+		} else {
+			p.Lines[i].Instruction = -1
+		}
+	}
+	return stats, nil
+}
+
+// constantLabelPrefix 为常量跳转目标生成的标签的前缀，后面是原来的ROM地址
+const constantLabelPrefix = "$rom."
+
+// labelConstantJumps 返回优化用的指令，其中 @N + 跳转 的@N改为@$rom.N，并在原来第N条指令之前插入标签($rom.N)，
+// 这样优化删除指令之后仍然跳到同一条指令
+func labelConstantJumps(instructions []Instruction) ([]optItem, error) {
+	var errs ErrorList
+	labels := map[string]bool{}
+	size := 0
+	for _, ins := range instructions {
+		if ins.Type == L_COMMAND {
+			labels[ins.Symbol] = true
+		} else {
+			size += 1
+		}
+	}
+	targets := map[int]string{}
+	symbols := map[int]string{}
+	for i, ins := range instructions {
+		if ins.Type != A_COMMAND || !isConstant(ins.Symbol) || i+1 >= len(instructions) || !instructions[i+1].isJump() {
+			continue
+		}
+		address, err := parseConstant(ins.Symbol)
+		if err != nil {
+			// 编码时报告
+			continue
+		}
+		if jump := instructions[i+1]; jump.usesA() || (jump.Jump != JMP && !jump.writesA() && !reloadsA(instructions, i+2)) {
+			errs = append(errs, ins.errorf(ins.Symbol, "jump to a constant address that is also used as a value cannot be optimized, use a label instead"))
+			continue
+		}
+		if int(address) > size {
+			errs = append(errs, ins.errorf(ins.Symbol, "jump to ROM[%d] outside the program of %d instructions cannot be optimized", address, size))
+			continue
+		}
+		if _, ok := targets[int(address)]; !ok {
+			label := constantLabelPrefix + strconv.Itoa(int(address))
+			for labels[label] {
+				label += "_"
+			}
+			labels[label] = true
+			targets[int(address)] = label
+		}
+		symbols[i] = targets[int(address)]
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]optItem, 0, len(instructions)+len(targets))
+	address := 0
+	for i, ins := range instructions {
+		if ins.Type != L_COMMAND {
+			if label, ok := targets[address]; ok {
+				items = append(items, optItem{Instruction: Instruction{Type: L_COMMAND, Pos: ins.Pos, Symbol: label}, index: -1})
+			}
+			address += 1
+		}
+		if label, ok := symbols[i]; ok {
+			ins.Symbol = label
+		}
+		items = append(items, optItem{Instruction: ins, index: i})
+	}
+	if label, ok := targets[size]; ok {
+		items = append(items, optItem{Instruction: Instruction{Type: L_COMMAND, Symbol: label}, index: -1})
+	}
+	return items, nil
+}
+
+// reloadsA 从i开始跳过标签后的指令是否重新加载A，即不会用到之前A中的值
+func reloadsA(instructions []Instruction, i int) bool {
+	for i < len(instructions) && instructions[i].Type == L_COMMAND {
+		i += 1
+	}
+	return i >= len(instructions) || instructions[i].Type == A_COMMAND
+}
+
+func countCode(items []optItem) int {
+	n := 0
+	for _, item := range items {
+		if item.Type != L_COMMAND {
+			n += 1
+		}
+	}
+	return n
+}
+
+func (ins Instruction) hasDest() bool {
+	return ins.Dest != "" && ins.Dest != "null"
+}
+
+func (ins Instruction) isJump() bool {
+	return ins.Type == C_COMMAND && ins.Jump != "" && ins.Jump != Null
+}
+
+// usesA C指令是否用到A的值：计算中的A或M，以及写入M
+func (ins Instruction) usesA() bool {
+	return ins.Type == C_COMMAND && (strings.ContainsAny(ins.Comp, "AM") || strings.Contains(ins.Dest, "M"))
+}
+
+// writesA C指令是否会改变A
+func (ins Instruction) writesA() bool {
+	return ins.Type == C_COMMAND && strings.Contains(ins.Dest, "A")
+}
+
+// isA 是否为@symbol
+func isA(items []optItem, i int, symbol string) bool {
+	return i < len(items) && items[i].Type == A_COMMAND && items[i].Symbol == symbol
+}
+
+// isC 是否为没有跳转的dest=comp
+func isC(items []optItem, i int, dest, comp string) bool {
+	return i < len(items) && items[i].Type == C_COMMAND && !items[i].isJump() && items[i].Dest == dest && items[i].Comp == comp
+}
+
+// overwritesA 从i开始的指令是否在使用A之前就重新加载了A，程序结尾同样视为A不再被使用
+func overwritesA(items []optItem, i int) bool {
+	return i >= len(items) || items[i].Type == A_COMMAND
+}
+
+// removeUnreachable 删除无条件跳转之后直到下一个标签的指令
+func removeUnreachable(items []optItem, stats *OptimizeStats) []optItem {
+	result := items[:0:0]
+	unreachable := false
+	for _, item := range items {
+		if item.Type == L_COMMAND {
+			unreachable = false
+		}
+		if unreachable {
+			stats.Unreachable += 1
+			continue
+		}
+		result = append(result, item)
+		if item.isJump() && item.Jump == JMP {
+			unreachable = true
+		}
+	}
+	return result
+}
+
+// removeNextJumps 删除跳到紧随其后的标签的跳转，标签之后的指令不使用A时连同@label一起删除
+func removeNextJumps(items []optItem, stats *OptimizeStats) []optItem {
+	result := items[:0:0]
+	for i := 0; i < len(items); i++ {
+		item := items[i]
+		if item.Type == A_COMMAND && i+1 < len(items) && items[i+1].isJump() && !items[i+1].hasDest() {
+			next := i + 2
+			target := false
+			for ; next < len(items) && items[next].Type == L_COMMAND; next++ {
+				target = target || items[next].Symbol == item.Symbol
+			}
+			if target {
+				if overwritesA(items, next) {
+					stats.Jumps += 2
+				} else {
+					stats.Jumps += 1
+					result = append(result, item)
+				}
+				i += 1
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// collapseStackOps 合并CodeWriter生成的栈操作：
+//
+//	M=x, A=M                        -> AM=x
+//	@SP, AM=M-1, ..., @SP, M=M+1    -> @SP, A=M-1, ...
+//	@SP, AM=M-1, ..., pushD         -> @SP, A=M-1, ..., M=D
+//	pushD, @SP, AM=M-1, D=M         -> @SP, A=M, M=D
+//
+// 其中...为不改变A也不跳转的C指令，第二、三种情况执行后A不同，因此要求随后的指令重新加载A。
+// 第四种情况保留对RAM[SP]的写入，CodeWriter生成的代码不会再读这个单元，手写的代码可能会读，
+// 这样合并前后A、D、SP以及RAM都相同，对任何输入都成立
+func collapseStackOps(items []optItem, stats *OptimizeStats) []optItem {
+	result := items[:0:0]
+	for i := 0; i < len(items); i++ {
+		if isC(items, i, "M", items[i].Comp) && isC(items, i+1, "A", "M") {
+			item := items[i]
+			item.Dest = "AM"
+			result = append(result, item)
+			stats.StackOps += 1
+			i += 1
+			continue
+		}
+		if isPushD(items, i) && isA(items, i+5, "SP") && isC(items, i+6, "AM", "M-1") && isC(items, i+7, "D", "M") {
+			result = append(result, items[i:i+3]...)
+			stats.StackOps += 5
+			i += 7
+			continue
+		}
+		if isA(items, i, "SP") && isC(items, i+1, "AM", "M-1") {
+			body := i + 2
+			for body < len(items) && items[body].Type == C_COMMAND && !items[body].isJump() && !items[body].writesA() {
+				body += 1
+			}
+			if isA(items, body, "SP") && isC(items, body+1, "M", "M+1") && overwritesA(items, body+2) {
+				result = appendPopTop(result, items[i:body])
+				stats.StackOps += 2
+				i = body + 1
+				continue
+			}
+			if isPushD(items, body) && overwritesA(items, body+5) {
+				result = appendPopTop(result, items[i:body])
+				result = append(result, items[body+2])
+				stats.StackOps += 4
+				i = body + 4
+				continue
+			}
+		}
+		result = append(result, items[i])
+	}
+	return result
+}
+
+// isPushD 是否为pushD：@SP, A=M, M=D, @SP, M=M+1
+func isPushD(items []optItem, i int) bool {
+	return isA(items, i, "SP") && isC(items, i+1, "A", "M") && isC(items, i+2, "M", "D") && isA(items, i+3, "SP") && isC(items, i+4, "M", "M+1")
+}
+
+// appendPopTop 将弹栈@SP, AM=M-1改为只定位栈顶的@SP, A=M-1，SP保持不变
+func appendPopTop(result []optItem, pop []optItem) []optItem {
+	top := pop[1]
+	top.Dest = "A"
+	result = append(result, pop[0], top)
+	return append(result, pop[2:]...)
+}
+
+// removeReloads 删除A中已经是该值的@X，以及随后立刻被覆盖的A指令和只写A的C指令
+func removeReloads(items []optItem, stats *OptimizeStats) []optItem {
+	result := items[:0:0]
+	loaded := ""
+	for i, item := range items {
+		switch item.Type {
+		case L_COMMAND:
+			loaded = ""
+		case A_COMMAND:
+			if item.Symbol == loaded || (i+1 < len(items) && items[i+1].Type == A_COMMAND) {
+				stats.Reloads += 1
+				continue
+			}
+			loaded = item.Symbol
+		case C_COMMAND:
+			if item.Dest == "A" && !item.isJump() && i+1 < len(items) && items[i+1].Type == A_COMMAND {
+				stats.Reloads += 1
+				continue
+			}
+			if item.writesA() {
+				loaded = ""
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
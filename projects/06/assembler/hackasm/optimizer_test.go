@@ -0,0 +1,266 @@
+package hackasm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nand2tetris/06/assembler/emulator"
+)
+
+// instructionText 指令的规范写法，用于比较优化结果
+func instructionText(ins Instruction) string {
+	switch ins.Type {
+	case A_COMMAND:
+		return "@" + ins.Symbol
+	case L_COMMAND:
+		return "(" + ins.Symbol + ")"
+	}
+	text := ins.Comp
+	if ins.Dest != "" && ins.Dest != "null" {
+		text = ins.Dest + "=" + text
+	}
+	if ins.Jump != "" && ins.Jump != Null {
+		text += ";" + string(ins.Jump)
+	}
+	return text
+}
+
+func optimizeSource(t *testing.T, source string) (string, OptimizeStats, error) {
+	t.Helper()
+	program, err := Parse("", strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	stats, err := program.Optimize()
+	lines := make([]string, 0, len(program.Instructions))
+	for _, ins := range program.Instructions {
+		lines = append(lines, instructionText(ins))
+	}
+	return strings.Join(lines, "\n"), stats, err
+}
+
+func TestOptimizeRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		expect string
+		stats  OptimizeStats
+	}{
+		{
+			"M=x, A=M to AM=x",
+			"@SP\nM=M+1\nA=M\nM=0",
+			"@SP\nAM=M+1\nM=0",
+			OptimizeStats{StackOps: 1},
+		},
+		{
+			"pop then push the result in place",
+			"@SP\nAM=M-1\nD=M\nD=D+1\n@SP\nA=M\nM=D\n@SP\nM=M+1\n@R0",
+			"@SP\nA=M-1\nD=M\nD=D+1\nM=D\n@R0",
+			OptimizeStats{StackOps: 4},
+		},
+		{
+			"pop then @SP M=M+1",
+			"@SP\nAM=M-1\nM=-M\n@SP\nM=M+1\n@R0",
+			"@SP\nA=M-1\nM=-M\n@R0",
+			OptimizeStats{StackOps: 2},
+		},
+		{
+			"no collapse when A is used after the push",
+			"@SP\nAM=M-1\nD=M\n@SP\nA=M\nM=D\n@SP\nM=M+1\nD=A",
+			"@SP\nAM=M-1\nD=M\n@SP\nA=M\nM=D\n@SP\nM=M+1\nD=A",
+			OptimizeStats{},
+		},
+		{
+			"pushD then popD keep only the store",
+			"@7\nD=A\n@SP\nA=M\nM=D\n@SP\nM=M+1\n@SP\nAM=M-1\nD=M\n@R1\nM=D",
+			"@7\nD=A\n@SP\nA=M\nM=D\n@R1\nM=D",
+			OptimizeStats{StackOps: 5},
+		},
+		{
+			"reload of the same symbol",
+			"@R0\nD=M\n@R0\nM=D+1",
+			"@R0\nD=M\nM=D+1",
+			OptimizeStats{Reloads: 1},
+		},
+		{
+			"A overwritten before use",
+			"@R1\n@R2\nD=M\n@R0\nA=D\n@R3\nM=0",
+			"@R2\nD=M\n@R3\nM=0",
+			OptimizeStats{Reloads: 3},
+		},
+		{
+			"labels keep reloads",
+			"@R0\nD=M\n(L)\n@R0\nM=D",
+			"@R0\nD=M\n(L)\n@R0\nM=D",
+			OptimizeStats{},
+		},
+		{
+			"jump to the next label",
+			"@NEXT\n0;JMP\n(NEXT)\n@R0\nM=0",
+			"(NEXT)\n@R0\nM=0",
+			OptimizeStats{Jumps: 2},
+		},
+		{
+			"jump to the next label that uses A",
+			"@NEXT\nD;JGT\n(NEXT)\nM=0",
+			"@NEXT\n(NEXT)\nM=0",
+			OptimizeStats{Jumps: 1},
+		},
+		{
+			"unreachable code after 0;JMP",
+			"@LOOP\n0;JMP\n@R0\nM=0\n(END)\nD=0\n(LOOP)\n@LOOP\n0;JMP\nD=1",
+			"@LOOP\n0;JMP\n(END)\nD=0\n(LOOP)\n@LOOP\n0;JMP",
+			OptimizeStats{Unreachable: 3},
+		},
+		{
+			"constant jump target becomes a label",
+			"@R2\n@10\nD=A\n@R0\nM=D\n@R0\nMD=M-1\n@5\nD;JGT",
+			"@10\nD=A\n@R0\nM=D\n($rom.5)\n@R0\nMD=M-1\n@$rom.5\nD;JGT",
+			OptimizeStats{Reloads: 1},
+		},
+		{
+			"constant jump to the end",
+			"@4\n0;JMP\n@R0\nM=0",
+			"($rom.4)",
+			OptimizeStats{Unreachable: 2, Jumps: 2},
+		},
+	}
+	for _, test := range tests {
+		got, stats, err := optimizeSource(t, test.source)
+		if err != nil {
+			t.Errorf("%s: optimize err: %v", test.name, err)
+			continue
+		}
+		if got != test.expect {
+			t.Errorf("%s: expect\n%s\ngot\n%s", test.name, test.expect, got)
+		}
+		test.stats.Before, test.stats.After = stats.Before, stats.After
+		if stats != test.stats {
+			t.Errorf("%s: expect stats %v, got %v", test.name, test.stats, stats)
+		}
+		if stats.Before-stats.After != stats.Reloads+stats.StackOps+stats.Unreachable+stats.Jumps {
+			t.Errorf("%s: removed %d instructions, stats %v", test.name, stats.Before-stats.After, stats)
+		}
+	}
+}
+
+func TestOptimizeConstantJumpErrors(t *testing.T) {
+	for _, source := range []string{
+		"@5\nM;JGT",        // 读取RAM[5]
+		"@5\nD=D-A;JGT",    // 计算中用到5
+		"@5\nM=D;JEQ",      // 写入RAM[5]
+		"@1\nD;JGT\nD=A",   // 不跳转时用到5
+		"@100\n0;JMP\nD=0", // 程序之外
+	} {
+		_, _, err := optimizeSource(t, source)
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 || errs[0].Line != 1 {
+			t.Errorf("%q: expect one error on line 1, got %v", source, err)
+		}
+	}
+}
+
+// runUntilEnd 执行到死循环或者PC离开程序
+func runUntilEnd(t *testing.T, words []uint16, ram map[int]int16) *emulator.Computer {
+	t.Helper()
+	c, err := emulator.NewComputer(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for address, value := range ram {
+		c.RAM[address] = uint16(value)
+	}
+	for int(c.PC) < len(words) && !c.Halted() {
+		if c.Cycles > 1000000 {
+			t.Fatalf("not halted after %d instructions", c.Cycles)
+		}
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// TestOptimizeEquivalence 用VM翻译器从07、08的课程VM程序生成的代码，以及跳到常量地址的程序，
+// 按课程.tst脚本设置RAM，比较优化前后执行结束时的RAM。栈顶之上是弹出的值，优化后可能不同。
+// testdata/vm中的代码由 translator -input <dir> 生成
+func TestOptimizeEquivalence(t *testing.T) {
+	segments := map[int]int16{0: 256, 1: 300, 2: 400, 3: 3000, 4: 3010}
+	tests := []struct {
+		name string
+		ram  map[int]int16
+	}{
+		{"SimpleAdd", segments},
+		{"StackTest", segments},
+		{"BasicTest", segments},
+		{"PointerTest", segments},
+		{"StaticTest", segments},
+		{"BasicLoop", map[int]int16{0: 256, 1: 300, 2: 400, 400: 3}},
+		{"FibonacciSeries", map[int]int16{0: 256, 1: 300, 2: 400, 400: 6, 401: 3000}},
+		{"SimpleFunction", map[int]int16{0: 317, 1: 317, 2: 310, 3: 3000, 4: 4000, 310: 1234, 311: 37, 312: 1000, 313: 305, 314: 300, 315: 3010, 316: 4010}},
+		{"FibonacciElement", nil},
+		{"NestedCall", nil},
+		{"StaticsTest", nil},
+	}
+	sources := map[string]string{}
+	for _, test := range tests {
+		source, err := os.ReadFile(filepath.Join("testdata", "vm", test.name+".asm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[test.name] = string(source)
+	}
+	constantJumps := "@R2\n@10\nD=A\n@R0\nM=D\n@R0\nMD=M-1\n@5\nD;JGT\n@R1\nM=1\n@13\n0;JMP\n@R1\nM=-1"
+	tests = append(tests, struct {
+		name string
+		ram  map[int]int16
+	}{"constant jumps", nil})
+	sources["constant jumps"] = constantJumps
+	// 手写的代码在弹栈之后读刚弹出的单元
+	poppedCell := "@SP\nM=0\n@256\nD=A\n@SP\nM=D\n@7\nD=A\n@SP\nA=M\nM=D\n@SP\nM=M+1\n@SP\nAM=M-1\nD=M\n" +
+		"@SP\nA=M\nD=M\n@R5\nM=D"
+	tests = append(tests, struct {
+		name string
+		ram  map[int]int16
+	}{"popped cell", nil})
+	sources["popped cell"] = poppedCell
+
+	for _, test := range tests {
+		plain, _, _, err := AssembleFile(test.name, strings.NewReader(sources[test.name]), Options{})
+		if err != nil {
+			t.Fatalf("%s: assemble err: %v", test.name, err)
+		}
+		optimized, _, program, err := AssembleFile(test.name, strings.NewReader(sources[test.name]), Options{Optimize: true})
+		if err != nil {
+			t.Fatalf("%s: assemble with -O err: %v", test.name, err)
+		}
+		if len(optimized) >= len(plain) {
+			t.Errorf("%s: expect fewer than %d instructions, got %d", test.name, len(plain), len(optimized))
+		}
+		expect, got := runUntilEnd(t, plain, test.ram), runUntilEnd(t, optimized, test.ram)
+		// 返回地址是ROM地址，沿LCL找出各个栈帧中的返回地址，和R13-R15一样不比较
+		sp := int(expect.RAM[0])
+		skip := map[int]bool{13: true, 14: true, 15: true}
+		for lcl := int(expect.RAM[1]); lcl >= 256+5 && lcl <= sp; {
+			skip[lcl-5] = true
+			saved := int(expect.RAM[lcl-4])
+			if saved >= lcl {
+				break
+			}
+			lcl = saved
+		}
+		for address := 0; address < emulator.RAMSize; address++ {
+			if skip[address] || (address >= sp && address < 2048) {
+				continue
+			}
+			if expect.RAM[address] != got.RAM[address] {
+				t.Errorf("%s: %v: RAM[%d] is %d, expect %d", test.name, program.Optimization, address, int16(got.RAM[address]), int16(expect.RAM[address]))
+			}
+		}
+		if got.Cycles >= expect.Cycles {
+			t.Errorf("%s: expect fewer than %d instructions executed, got %d", test.name, expect.Cycles, got.Cycles)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package hackasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxConstant A指令只有15位可以存放常量
+const maxConstant = 1<<15 - 1
+
+// isConstant 以数字、负号或单引号开头的都按常量解析，其余按符号处理
+func isConstant(str string) bool {
+	return len(str) > 0 && (unicode.IsDigit(rune(str[0])) || str[0] == '-' || str[0] == '\'')
+}
+
+// parseConstant 解析A指令中的常量，支持十进制、0x十六进制、0b二进制以及'A'字符常量，
+// 结果必须在0..32767之间。A指令的最高位固定为0，所以不支持负数：
+// 补码表示的负数最高位都是1，如-1即0xFFFF，只能先加载相反数再用C指令取负，如 @1 D=-A
+func parseConstant(str string) (int64, error) {
+	var val int64
+	var err error
+	switch {
+	case str[0] == '\'':
+		if len(str) != 3 || str[2] != '\'' || str[1] < ' ' || str[1] > '~' {
+			return 0, fmt.Errorf("invalid character constant, expect a single printable ASCII character like 'A'")
+		}
+		return int64(str[1]), nil
+	case strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X"):
+		val, err = strconv.ParseInt(str[2:], 16, 64)
+	case strings.HasPrefix(str, "0b") || strings.HasPrefix(str, "0B"):
+		val, err = strconv.ParseInt(str[2:], 2, 64)
+	default:
+		val, err = strconv.ParseInt(str, 10, 64)
+	}
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("constant out of range 0..%d", maxConstant)
+		}
+		return 0, fmt.Errorf("invalid constant")
+	}
+	if val < 0 {
+		return 0, fmt.Errorf("negative constant cannot be loaded by an A-instruction, its top bit is always 0; load %d and negate it with a C-instruction instead, e.g. D=-A", -val)
+	}
+	if val > maxConstant {
+		return 0, fmt.Errorf("constant %d out of range 0..%d, A-instructions only have 15 bits", val, maxConstant)
+	}
+	return val, nil
+}
+
+// isSymbol 符号由字母、数字、'_'、'.'、'$'、':'组成，且不能以数字开头
+func isSymbol(str string) bool {
+	if len(str) == 0 {
+		return false
+	}
+	for i, c := range str {
+		if unicode.IsDigit(c) {
+			if i == 0 {
+				return false
+			}
+			continue
+		}
+		if !unicode.IsLetter(c) && !strings.ContainsRune("_.$:", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func NewParser(reader io.Reader) Parser {
+	return Parser{
+		reader: bufio.NewReader(reader),
+	}
+}
+
+type Parser struct {
+	reader     *bufio.Reader
+	eof        bool
+	curLine    string
+	lineNo     int
+	curCommand Command
+}
+
+type CommandType int32
+
+const (
+	A_COMMAND   CommandType = 1
+	C_COMMAND   CommandType = 2
+	L_COMMAND   CommandType = 3
+	EMPTY_LINE  CommandType = 4
+	COMMENT     CommandType = 5
+	ERR_COMMAND CommandType = 6
+)
+
+type JumpType string
+
+const (
+	Null JumpType = "null"
+	JGT           = "JGT"
+	JEQ           = "JEQ"
+	JGE           = "JGE"
+	JLT           = "JLT"
+	JNE           = "JNE"
+	JLE           = "JLE"
+	JMP           = "JMP"
+)
+
+type Command struct {
+	commandType CommandType
+	symbol      string
+	destType    string
+	comp        string
+	jump        JumpType
+	col         int
+	cols        Columns
+}
+
+// Columns 指令各部分在行中的列号，从1开始，0表示指令没有这一部分
+type Columns struct {
+	Symbol int
+	Dest   int
+	Comp   int
+	Jump   int
+}
+
+func (p *Parser) HasMoreCommands() bool {
+	if p.eof {
+		return false
+	}
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		if err != io.EOF {
+			panic(err)
+		}
+		p.eof = true
+		if len(line) == 0 {
+			return false
+		}
+	}
+	p.curLine = strings.TrimRight(line, "\r\n")
+	p.lineNo += 1
+	return true
+}
+
+// Advance 解析当前行，语法错误时返回对应的AsmError，同时命令类型为ERR_COMMAND。
+// 词法单元之间可以有任意空白，如 D = M ; JGT，// 之后的内容都是注释
+func (p *Parser) Advance() *AsmError {
+	p.curCommand = Command{commandType: ERR_COMMAND}
+	lexer := NewLexer(p.curLine)
+	tokens := lexer.Tokens()
+	if len(tokens) == 0 {
+		if lexer.Comment {
+			p.curCommand.commandType = COMMENT
+		} else {
+			p.curCommand.commandType = EMPTY_LINE
+		}
+		return nil
+	}
+
+	curCommand := Command{col: tokens[0].Col}
+	var err *AsmError
+	switch tokens[0].Kind {
+	case TOKEN_AT: // A指令
+		curCommand.commandType = A_COMMAND
+		err = p.parseA(tokens, &curCommand)
+	case TOKEN_LPAREN: // L指令
+		curCommand.commandType = L_COMMAND
+		err = p.parseL(tokens, &curCommand)
+	default: // C指令
+		curCommand.commandType = C_COMMAND
+		err = p.parseC(tokens, &curCommand)
+	}
+	if err != nil {
+		return err
+	}
+	p.curCommand = curCommand
+	return nil
+}
+
+func (p *Parser) parseA(tokens []Token, command *Command) *AsmError {
+	if len(tokens) == 1 {
+		return p.errorAt(tokens[0], tokens[0].Text, "missing symbol or constant")
+	}
+	if tokens[1].Kind != TOKEN_WORD {
+		return p.errorAt(tokens[1], tokens[1].Text, "invalid symbol or constant")
+	}
+	if len(tokens) > 2 {
+		return p.errorAt(tokens[2], tokens[2].Text, "unexpected text after A-instruction")
+	}
+	command.symbol = tokens[1].Text
+	command.cols.Symbol = tokens[1].Col
+	return nil
+}
+
+func (p *Parser) parseL(tokens []Token, command *Command) *AsmError {
+	end := -1
+	for i, token := range tokens {
+		if token.Kind == TOKEN_RPAREN {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		last := tokens[len(tokens)-1]
+		return p.errorAt(tokens[0], p.curLine[tokens[0].Col-1:last.Col-1+len(last.Text)], "malformed label, missing ')'")
+	}
+	if end != len(tokens)-1 {
+		return p.errorAt(tokens[end+1], tokens[end+1].Text, "malformed label, unexpected text after ')'")
+	}
+	if end != 2 || tokens[1].Kind != TOKEN_WORD || !isSymbol(tokens[1].Text) {
+		return p.errorAt(tokens[0], p.curLine[tokens[0].Col-1:tokens[end].Col], "malformed label, invalid name")
+	}
+	command.symbol = tokens[1].Text
+	command.cols.Symbol = tokens[1].Col
+	return nil
+}
+
+// parseC 解析 [dest=]comp[;jump]，每一部分由若干词法单元拼接而成
+func (p *Parser) parseC(tokens []Token, command *Command) *AsmError {
+	var parts [3]string
+	var cols [3]int
+	part := 1
+	var equal, semicolon *Token
+	for i, token := range tokens {
+		switch token.Kind {
+		case TOKEN_EQUAL:
+			if equal != nil {
+				return p.errorAt(token, token.Text, "invalid grammar, too many '='")
+			}
+			if semicolon != nil {
+				return p.errorAt(token, token.Text, "invalid grammar, '=' after ';'")
+			}
+			equal = &tokens[i]
+			parts[0], cols[0] = parts[1], cols[1]
+			parts[1], cols[1] = "", 0
+		case TOKEN_SEMICOLON:
+			if semicolon != nil {
+				return p.errorAt(token, token.Text, "invalid grammar, too many ';'")
+			}
+			semicolon = &tokens[i]
+			part = 2
+		case TOKEN_WORD:
+			if cols[part] == 0 {
+				cols[part] = token.Col
+			}
+			parts[part] += token.Text
+		default:
+			return p.errorAt(token, token.Text, "unexpected '%s' in C-instruction", token.Text)
+		}
+	}
+	if equal != nil && parts[0] == "" {
+		return p.errorAt(*equal, equal.Text, "missing dest before '='")
+	}
+	if parts[1] == "" {
+		return p.errorAt(tokens[0], strings.TrimSpace(p.curLine[tokens[0].Col-1:]), "missing comp")
+	}
+	if semicolon != nil && parts[2] == "" {
+		return p.errorAt(*semicolon, semicolon.Text, "missing jump after ';'")
+	}
+
+	command.destType, command.comp, command.jump = "null", parts[1], Null
+	command.cols.Comp = cols[1]
+	if equal != nil {
+		command.destType = parts[0]
+		command.cols.Dest = cols[0]
+	}
+	if semicolon != nil {
+		command.jump = JumpType(parts[2])
+		command.cols.Jump = cols[2]
+	}
+	return nil
+}
+
+// LineNumber 当前命令所在的行号，从1开始
+func (p *Parser) LineNumber() int {
+	return p.lineNo
+}
+
+// Column 当前命令第一个字符的列号，从1开始
+func (p *Parser) Column() int {
+	return p.curCommand.col
+}
+
+// Columns 当前命令各部分的列号
+func (p *Parser) Columns() Columns {
+	return p.curCommand.cols
+}
+
+// errorAt 生成当前行中token处的错误
+func (p *Parser) errorAt(token Token, text string, format string, args ...interface{}) *AsmError {
+	return &AsmError{
+		Line: p.lineNo,
+		Col:  token.Col,
+		Text: text,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *Parser) CommandType() CommandType {
+	return p.curCommand.commandType
+}
+
+func (p *Parser) Symbol() string {
+	return p.curCommand.symbol
+}
+
+func (p *Parser) Dest() string {
+	return p.curCommand.destType
+}
+
+func (p *Parser) Comp() string {
+	return p.curCommand.comp
+}
+
+func (p *Parser) Jump() JumpType {
+	return p.curCommand.jump
+}
+
+// Pos 源码中的位置，行号和列号从1开始
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// Instruction 解析后的一条A指令、C指令或L伪指令
+type Instruction struct {
+	Type CommandType
+	Pos  Pos
+	// Symbol A指令的符号或常量，L指令的标签名
+	Symbol string
+	Dest   string
+	Comp   string
+	Jump   JumpType
+	// Source 指令所在的一行源码（预处理之后）
+	Source string
+	// Cols 各部分在Source中的列号
+	Cols Columns
+}
+
+func (ins Instruction) String() string {
+	switch ins.Type {
+	case A_COMMAND:
+		return "@" + ins.Symbol
+	case L_COMMAND:
+		return "(" + ins.Symbol + ")"
+	case C_COMMAND:
+		code := ins.Comp
+		if ins.Dest != "" && ins.Dest != "null" {
+			code = ins.Dest + "=" + code
+		}
+		if ins.Jump != "" && ins.Jump != Null {
+			code = code + ";" + string(ins.Jump)
+		}
+		return code
+	}
+	return ""
+}
+
+// errorf 生成指令上的错误，text为指令的某一部分时使用该部分的列号，否则取text在源码中的位置
+func (ins Instruction) errorf(text string, format string, args ...interface{}) *AsmError {
+	col := ins.Pos.Col
+	switch {
+	case text == "":
+	case text == ins.Symbol && ins.Cols.Symbol > 0:
+		col = ins.Cols.Symbol
+	case text == ins.Dest && ins.Cols.Dest > 0:
+		col = ins.Cols.Dest
+	case text == ins.Comp && ins.Cols.Comp > 0:
+		col = ins.Cols.Comp
+	case text == string(ins.Jump) && ins.Cols.Jump > 0:
+		col = ins.Cols.Jump
+	case strings.Contains(ins.Source, text):
+		col = strings.Index(ins.Source, text) + 1
+	}
+	return &AsmError{
+		File: ins.Pos.File,
+		Line: ins.Pos.Line,
+		Col:  col,
+		Text: text,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// SourceLine 预处理之后的一行源码，Instruction为该行指令在Program.Instructions中的下标，-1表示没有指令。
+// Origin为它在原始文件中的位置，宏展开得到的各行都来自调用宏的那一行
+type SourceLine struct {
+	Text        string
+	Origin      Origin
+	Instruction int
+}
+
+// Program 解析后的程序
+type Program struct {
+	Instructions []Instruction
+	Lines        []SourceLine
+	// Optimization 经过Optimize时的统计，否则为nil
+	Optimization *OptimizeStats
+	// Exports Imports 源码中#export和#import声明的符号，用于生成目标文件
+	Exports []LinkSymbol
+	Imports []LinkSymbol
+}
+
+// Parse 预处理并解析源码，语法错误以ErrorList返回，此时Program中只包含正确解析的指令
+func Parse(filename string, reader io.Reader) (*Program, error) {
+	program, errs := parse(filename, reader)
+	return program, errs.Err()
+}
+
+func parse(filename string, reader io.Reader) (*Program, ErrorList) {
+	source, err := Preprocess(filename, reader)
+	if err != nil {
+		if errs, ok := err.(ErrorList); ok {
+			return &Program{}, errs
+		}
+		return &Program{}, ErrorList{{File: filename, Msg: err.Error()}}
+	}
+
+	var errs ErrorList
+	program := &Program{Exports: source.Exports, Imports: source.Imports}
+	parser := NewParser(strings.NewReader(source.Text()))
+	for parser.HasMoreCommands() {
+		origin, _ := source.Origin(parser.LineNumber())
+		program.Lines = append(program.Lines, SourceLine{Text: parser.curLine, Origin: origin, Instruction: -1})
+		if err := parser.Advance(); err != nil {
+			err.File = filename
+			source.Locate(err)
+			errs = append(errs, err)
+			continue
+		}
+		commandType := parser.CommandType()
+		if commandType != A_COMMAND && commandType != C_COMMAND && commandType != L_COMMAND {
+			continue
+		}
+		pos := Pos{File: filename, Line: parser.LineNumber(), Col: parser.Column()}
+		if origin, ok := source.Origin(pos.Line); ok {
+			pos.File, pos.Line = origin.File, origin.Line
+		}
+		program.Lines[len(program.Lines)-1].Instruction = len(program.Instructions)
+		program.Instructions = append(program.Instructions, Instruction{
+			Type:   commandType,
+			Pos:    pos,
+			Symbol: parser.Symbol(),
+			Dest:   parser.Dest(),
+			Comp:   parser.Comp(),
+			Jump:   parser.Jump(),
+			Source: parser.curLine,
+			Cols:   parser.Columns(),
+		})
+	}
+	return program, errs
+}
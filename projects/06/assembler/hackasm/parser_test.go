@@ -0,0 +1,133 @@
+package hackasm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		constant string
+		expect   uint16
+	}{
+		{"0", 0},
+		{"32767", 32767},
+		{"0x7FFF", 0x7fff},
+		{"0x4000", 16384},
+		{"0X1f", 31},
+		{"0b101", 5},
+		{"0B0", 0},
+		{"'A'", 65},
+		{"' '", 32},
+		{"'('", 40},
+		{"'~'", 126},
+		{"007", 7},
+		{"-0", 0},
+	}
+	for _, test := range tests {
+		words, table, err := Assemble(strings.NewReader("@" + test.constant))
+		if err != nil {
+			t.Errorf("@%s: assemble err: %v", test.constant, err)
+			continue
+		}
+		if words[0] != test.expect {
+			t.Errorf("@%s: expect %d, got %d", test.constant, test.expect, words[0])
+		}
+		if table.NextVariableAddress() != VariableBase {
+			t.Errorf("@%s: constant allocated as a variable", test.constant)
+		}
+	}
+}
+
+func TestInvalidConstants(t *testing.T) {
+	tests := []struct {
+		constant string
+		msg      string
+	}{
+		{"32768", "out of range"},
+		{"0x8000", "out of range"},
+		{"0b1000000000000000", "out of range"},
+		{"99999999999999999999", "out of range"},
+		{"-1", "negative constant"},
+		{"-32768", "negative constant"},
+		{"0x", "invalid constant"},
+		{"0xG1", "invalid constant"},
+		{"0b102", "invalid constant"},
+		{"12ab", "invalid constant"},
+		{"'AB'", "invalid character constant"},
+		{"''", "invalid character constant"},
+	}
+	for _, test := range tests {
+		_, _, err := Assemble(strings.NewReader("@" + test.constant))
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 {
+			t.Errorf("@%s: expect one error, got %v", test.constant, err)
+			continue
+		}
+		if !strings.Contains(errs[0].Msg, test.msg) || errs[0].Line != 1 || errs[0].Col != 2 {
+			t.Errorf("@%s: expect %q at 1:2, got %v", test.constant, test.msg, errs[0])
+		}
+	}
+}
+
+// TestParseColumns 指令内部可以有空白，A指令和标签之后可以有注释，各部分的列号指向原文
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		line   string
+		expect Instruction
+	}{
+		{"D=M;JGT", Instruction{Type: C_COMMAND, Dest: "D", Comp: "M", Jump: JGT, Cols: Columns{Dest: 1, Comp: 3, Jump: 5}}},
+		{"  D = M ; JGT", Instruction{Type: C_COMMAND, Dest: "D", Comp: "M", Jump: JGT, Cols: Columns{Dest: 3, Comp: 7, Jump: 11}}},
+		{"\tAM = M-1 // pop", Instruction{Type: C_COMMAND, Dest: "AM", Comp: "M-1", Jump: Null, Cols: Columns{Dest: 2, Comp: 7}}},
+		{"0 ; JMP", Instruction{Type: C_COMMAND, Dest: "null", Comp: "0", Jump: JMP, Cols: Columns{Comp: 1, Jump: 5}}},
+		{"M=M+1", Instruction{Type: C_COMMAND, Dest: "M", Comp: "M+1", Jump: Null, Cols: Columns{Dest: 1, Comp: 3}}},
+		{"@i // counter", Instruction{Type: A_COMMAND, Symbol: "i", Cols: Columns{Symbol: 2}}},
+		{"   @ 100", Instruction{Type: A_COMMAND, Symbol: "100", Cols: Columns{Symbol: 6}}},
+		{"@LOOP//next", Instruction{Type: A_COMMAND, Symbol: "LOOP", Cols: Columns{Symbol: 2}}},
+		{"(LOOP) // start", Instruction{Type: L_COMMAND, Symbol: "LOOP", Cols: Columns{Symbol: 2}}},
+		{"  ( END )", Instruction{Type: L_COMMAND, Symbol: "END", Cols: Columns{Symbol: 5}}},
+	}
+	for _, test := range tests {
+		program, err := Parse("prog.asm", strings.NewReader("// header\n"+test.line))
+		if err != nil {
+			t.Errorf("%q: parse err: %v", test.line, err)
+			continue
+		}
+		if len(program.Instructions) != 1 {
+			t.Errorf("%q: expect one instruction, got %d", test.line, len(program.Instructions))
+			continue
+		}
+		got := program.Instructions[0]
+		expect := test.expect
+		if got.Type != expect.Type || got.Symbol != expect.Symbol || got.Dest != expect.Dest || got.Comp != expect.Comp || got.Jump != expect.Jump || got.Cols != expect.Cols {
+			t.Errorf("%q: expect %d %q %q %q %q %+v, got %d %q %q %q %q %+v", test.line,
+				expect.Type, expect.Symbol, expect.Dest, expect.Comp, expect.Jump, expect.Cols,
+				got.Type, got.Symbol, got.Dest, got.Comp, got.Jump, got.Cols)
+		}
+		if got.Pos.File != "prog.asm" || got.Pos.Line != 2 {
+			t.Errorf("%q: expect position prog.asm:2, got %s:%d", test.line, got.Pos.File, got.Pos.Line)
+		}
+	}
+}
+
+// TestParseErrorColumns 错误的列号指向出错的那一部分
+func TestParseErrorColumns(t *testing.T) {
+	tests := []struct {
+		line   string
+		expect string
+	}{
+		{"D = Q ; JGT", "1:5: unknown comp mnemonic: 'Q'"},
+		{"D = M ; JXX", "1:9: unknown jump mnemonic: 'JXX'"},
+		{"  X = M", "1:3: unknown dest mnemonic: 'X'"},
+		{"   @ 1x // bad", "1:6: invalid constant: '1x'"},
+		{"  (END // no paren", "1:3: malformed label, missing ')': '(END'"},
+		{"@i @j", "1:4: unexpected text after A-instruction: '@'"},
+		{"(END) @END", "1:7: malformed label, unexpected text after ')': '@'"},
+	}
+	for _, test := range tests {
+		_, _, _, err := AssembleFile("", strings.NewReader(test.line), Options{})
+		if err == nil || err.Error() != test.expect {
+			t.Errorf("%q: expect %q, got %v", test.line, test.expect, err)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package hackasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// builtinMacros 与VM翻译器中pushD/popD等写法相同的常用宏，可以被同名宏覆盖
+const builtinMacros = `
+#macro PUSH_D
+@SP
+A=M
+M=D
+@SP
+M=M+1
+#endmacro
+
+#macro POP_D
+@SP
+M=M-1
+A=M
+D=M
+#endmacro
+
+#macro GOTO label
+@label
+0;JMP
+#endmacro
+`
+
+// maxExpandDepth 宏嵌套展开和include的最大深度，防止递归定义导致死循环
+const maxExpandDepth = 32
+
+// Origin 预处理后的一行对应的源文件位置，Text为该位置原始的一行源码
+type Origin struct {
+	File string
+	Line int
+	Text string
+}
+
+// PreprocessedSource 预处理后的源码，Origins[i]是第i+1行的来源
+type PreprocessedSource struct {
+	Lines   []string
+	Origins []Origin
+	// Exports #export声明的符号，Imports #import声明的符号
+	Exports []LinkSymbol
+	Imports []LinkSymbol
+}
+
+// LinkSymbol #export或#import声明的一个符号
+type LinkSymbol struct {
+	Name string
+	Pos  Pos
+}
+
+func (s *PreprocessedSource) Text() string {
+	return strings.Join(s.Lines, "\n")
+}
+
+// Origin 预处理后第line行对应的源文件位置
+func (s *PreprocessedSource) Origin(line int) (Origin, bool) {
+	if line < 1 || line > len(s.Origins) {
+		return Origin{}, false
+	}
+	return s.Origins[line-1], true
+}
+
+// Locate 将预处理后源码中的错误位置映射回原始文件
+func (s *PreprocessedSource) Locate(err *AsmError) {
+	if origin, ok := s.Origin(err.Line); ok {
+		err.File = origin.File
+		err.Line = origin.Line
+	}
+}
+
+type macro struct {
+	name   string
+	params []string
+	body   []string
+	origin Origin
+	// duplicate 重复定义的宏，读完宏体后丢弃
+	duplicate bool
+}
+
+// Preprocessor 在两遍汇编之前展开 #include、#define 以及宏：
+//
+//	#include "file.asm"          相对于当前文件所在目录
+//	#define NAME value           之后出现的NAME都替换为value
+//	#macro NAME [param ...]      定义宏，宏体中的参数按整词替换，
+//	...                          %% 替换为每次展开唯一的编号，用于宏内部的标签
+//	#endmacro
+//	NAME arg1, arg2              展开宏
+//	#export NAME ...             将本文件定义的标签提供给其他目标文件
+//	#import NAME ...             使用其他目标文件导出的标签，由链接器解析
+type Preprocessor struct {
+	// Open 打开include的文件，默认为os.Open
+	Open func(path string) (io.ReadCloser, error)
+
+	defines   map[string]string
+	macros    map[string]*macro
+	expandSeq int
+	included  map[string]bool
+	errs      ErrorList
+	output    PreprocessedSource
+}
+
+func NewPreprocessor() *Preprocessor {
+	p := &Preprocessor{
+		Open: func(path string) (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		defines: map[string]string{},
+		macros:  map[string]*macro{},
+	}
+	p.process("<builtin>", strings.NewReader(builtinMacros), 0)
+	p.output = PreprocessedSource{}
+	return p
+}
+
+// Preprocess 预处理filename的内容，出错时返回ErrorList
+func Preprocess(filename string, reader io.Reader) (*PreprocessedSource, error) {
+	return NewPreprocessor().Process(filename, reader)
+}
+
+func (p *Preprocessor) Process(filename string, reader io.Reader) (*PreprocessedSource, error) {
+	p.included = map[string]bool{filepath.Clean(filename): true}
+	p.process(filename, reader, 0)
+	if err := p.errs.Err(); err != nil {
+		return nil, err
+	}
+	return &p.output, nil
+}
+
+func (p *Preprocessor) errorf(origin Origin, text string, format string, args ...interface{}) {
+	p.errs = append(p.errs, &AsmError{
+		File: origin.File,
+		Line: origin.Line,
+		Col:  1,
+		Text: text,
+		Msg:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (p *Preprocessor) emit(line string, origin Origin) {
+	p.output.Lines = append(p.output.Lines, line)
+	p.output.Origins = append(p.output.Origins, origin)
+}
+
+func (p *Preprocessor) process(filename string, reader io.Reader, depth int) {
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	var defining *macro
+	for scanner.Scan() {
+		lineNo += 1
+		rawLine := scanner.Text()
+		origin := Origin{File: filename, Line: lineNo, Text: rawLine}
+		code, _ := splitComment(rawLine)
+		fields := strings.Fields(code)
+
+		if defining != nil || (len(fields) > 0 && strings.HasPrefix(fields[0], "#")) {
+			// 指令和宏定义不生成代码，留下空行使列表文件仍能显示它们
+			p.emit("", origin)
+		}
+		if defining != nil {
+			if len(fields) > 0 && fields[0] == "#endmacro" {
+				if !defining.duplicate {
+					p.macros[defining.name] = defining
+				}
+				defining = nil
+				continue
+			}
+			if len(fields) > 0 && fields[0] == "#macro" {
+				p.errorf(origin, code, "nested macro definition")
+				continue
+			}
+			defining.body = append(defining.body, rawLine)
+			continue
+		}
+
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "#") {
+			p.expandLine(rawLine, origin, depth)
+			continue
+		}
+
+		switch fields[0] {
+		case "#include":
+			if len(fields) != 2 || len(fields[1]) < 2 || fields[1][0] != '"' || fields[1][len(fields[1])-1] != '"' {
+				p.errorf(origin, code, "expect #include \"file.asm\"")
+				continue
+			}
+			p.include(filename, fields[1][1:len(fields[1])-1], origin, depth)
+		case "#define":
+			if len(fields) < 3 || !isSymbol(fields[1]) {
+				p.errorf(origin, code, "expect #define NAME value")
+				continue
+			}
+			p.defines[fields[1]] = strings.Join(fields[2:], " ")
+		case "#macro":
+			if len(fields) < 2 || !isSymbol(fields[1]) {
+				p.errorf(origin, code, "expect #macro NAME [param ...]")
+				continue
+			}
+			defining = &macro{name: fields[1], params: splitArgs(strings.Join(fields[2:], " ")), origin: origin}
+			if old, ok := p.macros[fields[1]]; ok && old.origin.File != "<builtin>" {
+				p.errorf(origin, fields[1], "duplicate macro, first defined at %s:%d", old.origin.File, old.origin.Line)
+				defining.duplicate = true
+			}
+		case "#export", "#import":
+			if len(fields) < 2 {
+				p.errorf(origin, code, "expect %s NAME ...", fields[0])
+				continue
+			}
+			for _, name := range fields[1:] {
+				if !isSymbol(name) {
+					p.errorf(origin, name, "invalid symbol")
+					continue
+				}
+				symbol := LinkSymbol{Name: name, Pos: Pos{File: origin.File, Line: origin.Line, Col: strings.Index(rawLine, name) + 1}}
+				if fields[0] == "#export" {
+					p.output.Exports = append(p.output.Exports, symbol)
+				} else {
+					p.output.Imports = append(p.output.Imports, symbol)
+				}
+			}
+		case "#endmacro":
+			p.errorf(origin, code, "#endmacro without #macro")
+		default:
+			p.errorf(origin, fields[0], "unknown directive")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		p.errorf(Origin{File: filename, Line: lineNo}, "", "read err: %v", err)
+	}
+	if defining != nil {
+		p.errorf(defining.origin, defining.name, "missing #endmacro")
+	}
+}
+
+func (p *Preprocessor) include(filename, name string, origin Origin, depth int) {
+	if depth >= maxExpandDepth {
+		p.errorf(origin, name, "include nested too deeply")
+		return
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(filename), name)
+	}
+	path = filepath.Clean(path)
+	if p.included[path] {
+		p.errorf(origin, name, "recursive include")
+		return
+	}
+	f, err := p.Open(path)
+	if err != nil {
+		p.errorf(origin, name, "include err: %v", err)
+		return
+	}
+	defer f.Close()
+	p.included[path] = true
+	p.process(path, f, depth+1)
+	delete(p.included, path)
+}
+
+// expandLine 替换#define的常量，如果是宏调用则展开宏
+func (p *Preprocessor) expandLine(rawLine string, origin Origin, depth int) {
+	code, comment := splitComment(rawLine)
+	code = replaceWords(code, p.defines)
+	fields := strings.Fields(code)
+	if len(fields) == 0 {
+		p.emit(rawLine, origin)
+		return
+	}
+	m, ok := p.macros[fields[0]]
+	if !ok {
+		p.emit(code+comment, origin)
+		return
+	}
+	if depth >= maxExpandDepth {
+		p.errorf(origin, m.name, "macro nested too deeply")
+		return
+	}
+	trimmed := strings.TrimSpace(code)
+	args := splitArgs(strings.TrimSpace(trimmed[len(m.name):]))
+	if len(args) != len(m.params) {
+		p.errorf(origin, trimmed, "macro %s expects %d arguments, got %d", m.name, len(m.params), len(args))
+		return
+	}
+	p.expandSeq += 1
+	replacements := map[string]string{}
+	for i, param := range m.params {
+		replacements[param] = args[i]
+	}
+	for _, bodyLine := range m.body {
+		bodyCode, bodyComment := splitComment(bodyLine)
+		bodyCode = strings.ReplaceAll(replaceWords(bodyCode, replacements), "%%", strconv.Itoa(p.expandSeq))
+		p.expandLine(bodyCode+bodyComment, origin, depth+1)
+	}
+}
+
+// splitComment 将一行拆分为代码和 // 开始的注释
+func splitComment(line string) (string, string) {
+	if index := strings.Index(line, "//"); index != -1 {
+		return line[:index], line[index:]
+	}
+	return line, ""
+}
+
+// splitArgs 宏参数以空白或','分隔
+func splitArgs(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+func isSymbolChar(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c == ':' ||
+		(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// replaceWords 按整词替换，单词由符号允许的字符组成
+func replaceWords(s string, replacements map[string]string) string {
+	if len(replacements) == 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if !isSymbolChar(s[i]) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && isSymbolChar(s[j]) {
+			j++
+		}
+		word := s[i:j]
+		if replacement, ok := replacements[word]; ok {
+			b.WriteString(replacement)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,228 @@
+package hackasm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// preprocessFiles 预处理files中的main.asm，#include从files中读取
+func preprocessFiles(files map[string]string) (*PreprocessedSource, error) {
+	p := NewPreprocessor()
+	p.Open = func(path string) (io.ReadCloser, error) {
+		source, ok := files[filepath.ToSlash(path)]
+		if !ok {
+			return nil, fmt.Errorf("open %s: %w", path, os.ErrNotExist)
+		}
+		return io.NopCloser(strings.NewReader(source)), nil
+	}
+	return p.Process("src/main.asm", strings.NewReader(files["src/main.asm"]))
+}
+
+// code 预处理后的非空行
+func code(source *PreprocessedSource) string {
+	var lines []string
+	for _, line := range source.Lines {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestPreprocess(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		expect string
+	}{
+		{
+			"include relative to the including file",
+			map[string]string{
+				"src/main.asm":  "#include \"lib/a.asm\"\n@main",
+				"src/lib/a.asm": "@a\n#include \"b.asm\"",
+				"src/lib/b.asm": "#include \"../c.asm\"\n@b",
+				"src/c.asm":     "@c",
+				"src/lib/c.asm": "@wrong",
+			},
+			"@a\n@c\n@b\n@main",
+		},
+		{
+			"include the same file twice",
+			map[string]string{
+				"src/main.asm": "#include \"a.asm\"\n#include \"a.asm\"",
+				"src/a.asm":    "@a",
+			},
+			"@a\n@a",
+		},
+		{
+			"define replaces whole words only",
+			map[string]string{
+				"src/main.asm": "#define N 5\n#define MASK 0x00FF\n@N\n@NN\n@N.x\n@MASK // N stays in comments\nD=D&A",
+			},
+			"@5\n@NN\n@N.x\n@0x00FF // N stays in comments\nD=D&A",
+		},
+		{
+			"macro parameters and defines",
+			map[string]string{
+				"src/main.asm": "#define ONE 1\n#macro SET addr, value\n@value\nD=A\n@addr\nM=D\n#endmacro\nSET R0, ONE",
+			},
+			"@1\nD=A\n@R0\nM=D",
+		},
+		{
+			"%% is unique for each expansion",
+			map[string]string{
+				"src/main.asm": "#macro WAIT\n(WAIT%%)\n@WAIT%%\n0;JMP\n#endmacro\nWAIT\nWAIT",
+			},
+			"(WAIT1)\n@WAIT1\n0;JMP\n(WAIT2)\n@WAIT2\n0;JMP",
+		},
+		{
+			"macros expand inside macros",
+			map[string]string{
+				"src/main.asm": "#macro INC_PUSH\nD=D+1\nPUSH_D\n#endmacro\nINC_PUSH",
+			},
+			"D=D+1\n@SP\nA=M\nM=D\n@SP\nM=M+1",
+		},
+		{
+			"override a builtin macro",
+			map[string]string{
+				"src/main.asm": "#macro GOTO label\n@label\nD;JMP\n#endmacro\nGOTO END",
+			},
+			"@END\nD;JMP",
+		},
+	}
+	for _, test := range tests {
+		source, err := preprocessFiles(test.files)
+		if err != nil {
+			t.Errorf("%s: preprocess err: %v", test.name, err)
+			continue
+		}
+		if got := code(source); got != test.expect {
+			t.Errorf("%s: expect\n%s\ngot\n%s", test.name, test.expect, got)
+		}
+	}
+}
+
+func TestPreprocessErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		pos   string
+		msg   string
+	}{
+		{
+			"recursive include",
+			map[string]string{
+				"src/main.asm": "@main\n#include \"a.asm\"",
+				"src/a.asm":    "#include \"b.asm\"",
+				"src/b.asm":    "\n#include \"a.asm\"",
+			},
+			"src/b.asm:2", "recursive include",
+		},
+		{
+			"include itself",
+			map[string]string{"src/main.asm": "#include \"main.asm\""},
+			"src/main.asm:1", "recursive include",
+		},
+		{
+			"missing include",
+			map[string]string{"src/main.asm": "\n\n#include \"none.asm\""},
+			"src/main.asm:3", "include err",
+		},
+		{
+			"include without quotes",
+			map[string]string{"src/main.asm": "#include a.asm"},
+			"src/main.asm:1", "expect #include",
+		},
+		{
+			"too few macro arguments",
+			map[string]string{"src/main.asm": "#macro SET addr, value\n@value\n#endmacro\nSET R0"},
+			"src/main.asm:4", "macro SET expects 2 arguments, got 1",
+		},
+		{
+			"too many macro arguments",
+			map[string]string{"src/main.asm": "PUSH_D D"},
+			"src/main.asm:1", "macro PUSH_D expects 0 arguments, got 1",
+		},
+		{
+			"duplicate macro",
+			map[string]string{"src/main.asm": "#macro M\n#endmacro\n#macro M\n#endmacro"},
+			"src/main.asm:3", "duplicate macro, first defined at src/main.asm:1",
+		},
+		{
+			"recursive macro",
+			map[string]string{"src/main.asm": "#macro LOOP\nLOOP\n#endmacro\nLOOP"},
+			"src/main.asm:4", "macro nested too deeply",
+		},
+		{
+			"missing #endmacro",
+			map[string]string{"src/main.asm": "@0\n#macro M\n@1"},
+			"src/main.asm:2", "missing #endmacro",
+		},
+		{
+			"unknown directive",
+			map[string]string{"src/main.asm": "#ifdef X"},
+			"src/main.asm:1", "unknown directive",
+		},
+	}
+	for _, test := range tests {
+		_, err := preprocessFiles(test.files)
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 {
+			t.Errorf("%s: expect one error, got %v", test.name, err)
+			continue
+		}
+		pos := fmt.Sprintf("%s:%d", errs[0].File, errs[0].Line)
+		if pos != test.pos || !strings.Contains(errs[0].Msg, test.msg) {
+			t.Errorf("%s: expect %q at %s, got %v", test.name, test.msg, test.pos, errs[0])
+		}
+	}
+}
+
+// TestLocate 预处理后源码中的行号映射回原始文件，宏展开的行对应调用宏的行
+func TestLocate(t *testing.T) {
+	source, err := preprocessFiles(map[string]string{
+		"src/main.asm": "#define N 2\n@N\n#include \"lib.asm\"\nPUSH_D\nD=X",
+		"src/lib.asm":  "// lib\nD=Y",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"@2":    "src/main.asm:2",
+		"D=Y":   "src/lib.asm:2",
+		"M=M+1": "src/main.asm:4",
+		"D=X":   "src/main.asm:5",
+	}
+	for i, line := range source.Lines {
+		want, ok := expect[strings.TrimSpace(line)]
+		if !ok {
+			continue
+		}
+		delete(expect, strings.TrimSpace(line))
+		err := &AsmError{File: "src/main.asm", Line: i + 1, Col: 1}
+		source.Locate(err)
+		if got := fmt.Sprintf("%s:%d", err.File, err.Line); got != want {
+			t.Errorf("%s: expect %s, got %s", line, want, got)
+		}
+	}
+	if len(expect) != 0 {
+		t.Errorf("lines not found in the preprocessed source: %v", expect)
+	}
+	if origin, _ := source.Origin(len(source.Lines)); origin.Text != "D=X" {
+		t.Errorf("expect the original text D=X, got %q", origin.Text)
+	}
+}
+
+// TestAssembleLocatesErrors 汇编错误报告在宏调用和#define所在的原始行
+func TestAssembleLocatesErrors(t *testing.T) {
+	source := "#define BAD X\n#macro LOAD value\n@0\nD=value\n#endmacro\n\nLOAD BAD"
+	_, _, err := Assemble(strings.NewReader(source))
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Line != 7 {
+		t.Fatalf("expect one error on line 7, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package hackasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// ROMSize 指令内存的大小
+	ROMSize = 32768
+	// RAMSize 数据内存的大小，包括屏幕和键盘
+	RAMSize = 32768
+	// VariableBase 默认从RAM[16]开始分配变量
+	VariableBase = 16
+	// VariableLimit 默认的变量上限，变量不能进入从SCREEN开始的内存映射区域
+	VariableLimit = 16384
+)
+
+func NewSymbolTalbe() SymbolTable {
+	initTable := map[string]int{
+		"SP":     0,
+		"LCL":    1,
+		"ARG":    2,
+		"THIS":   3,
+		"THAT":   4,
+		"SCREEN": 16384,
+		"KBD":    24576,
+	}
+	for i := 0; i <= 15; i++ {
+		initTable[fmt.Sprintf("R%d", i)] = i
+	}
+
+	kinds := make(map[string]SymbolKind, len(initTable))
+	for symbol := range initTable {
+		kinds[symbol] = PREDEFINED
+	}
+
+	return SymbolTable{
+		table:           initTable,
+		kinds:           kinds,
+		variableAddress: VariableBase,
+	}
+}
+
+type SymbolKind string
+
+const (
+	PREDEFINED SymbolKind = "predefined"
+	LABEL      SymbolKind = "label"
+	VARIABLE   SymbolKind = "variable"
+)
+
+type SymbolTable struct {
+	table           map[string]int
+	kinds           map[string]SymbolKind
+	variableAddress int
+}
+
+func (s *SymbolTable) AddEntry(symbol string, address int) {
+	s.table[symbol] = address
+	s.kinds[symbol] = LABEL
+}
+
+func (s *SymbolTable) AddVariable(symbol string) {
+	s.table[symbol] = s.variableAddress
+	s.kinds[symbol] = VARIABLE
+	s.variableAddress += 1
+}
+
+// SetVariableBase 设置下一个变量的地址
+func (s *SymbolTable) SetVariableBase(address int) {
+	s.variableAddress = address
+}
+
+// NextVariableAddress 下一个变量将被分配的地址
+func (s *SymbolTable) NextVariableAddress() int {
+	return s.variableAddress
+}
+
+// Kind 符号的种类：预定义符号、标签或变量
+func (s *SymbolTable) Kind(symbol string) SymbolKind {
+	return s.kinds[symbol]
+}
+
+func (s *SymbolTable) Contains(symbol string) bool {
+	_, ok := s.table[symbol]
+	return ok
+}
+
+func (s *SymbolTable) GetAddress(symbol string) int {
+	address := s.table[symbol]
+	return address
+}
+
+// Symbols 所有符号，按预定义符号、标签、变量分组，组内按地址排序
+func (s *SymbolTable) Symbols() []string {
+	order := map[SymbolKind]int{PREDEFINED: 0, LABEL: 1, VARIABLE: 2}
+	symbols := make([]string, 0, len(s.table))
+	for symbol := range s.table {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if s.Kind(a) != s.Kind(b) {
+			return order[s.Kind(a)] < order[s.Kind(b)]
+		}
+		if s.GetAddress(a) != s.GetAddress(b) {
+			return s.GetAddress(a) < s.GetAddress(b)
+		}
+		return a < b
+	})
+	return symbols
+}
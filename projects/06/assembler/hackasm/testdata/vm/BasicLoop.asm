@@ -0,0 +1,135 @@
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+(.LOOP_START)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@LCL
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@.LOOP_START
+D;JNE
+@LCL
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
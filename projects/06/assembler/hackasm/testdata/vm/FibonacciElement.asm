@@ -0,0 +1,498 @@
+@256
+D=A
+@SP
+M=D
+@FibonacciElement.asm.return.0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@0
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Sys.init
+0;JMP
+(FibonacciElement.asm.return.0)
+(Main.fibonacci)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@2
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.0
+D;JLT
+@13
+D=M
+@sameSign.0
+D;JGE
+@signDiffer.0
+0;JMP
+(xNegative.0)
+@13
+D=M
+@sameSign.0
+D;JLT
+(signDiffer.0)
+@SP
+A=M
+D=M
+@writeTrue.0
+D;JLT
+@setFalse.0
+0;JMP
+(sameSign.0)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.0
+D;JLT
+(setFalse.0)
+D=0
+@writeFalse.0
+0;JMP
+(writeTrue.0)
+D=-1
+(writeFalse.0)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@Main.fibonacci.IF_TRUE
+D;JNE
+@Main.fibonacci.IF_FALSE
+0;JMP
+(Main.fibonacci.IF_TRUE)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
+(Main.fibonacci.IF_FALSE)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@2
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@Main.fibonacci.return.0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@1
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Main.fibonacci
+0;JMP
+(Main.fibonacci.return.0)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@Main.fibonacci.return.1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@1
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Main.fibonacci
+0;JMP
+(Main.fibonacci.return.1)
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
+(Sys.init)
+@4
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Sys.init.return.2
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@1
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Main.fibonacci
+0;JMP
+(Sys.init.return.2)
+(Sys.init.WHILE)
+@Sys.init.WHILE
+0;JMP
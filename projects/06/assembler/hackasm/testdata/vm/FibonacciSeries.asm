@@ -0,0 +1,238 @@
+@ARG
+D=M
+@1
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@4
+M=D
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@1
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@2
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+(.MAIN_LOOP_START)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@.COMPUTE_ELEMENT
+D;JNE
+@.END_PROGRAM
+0;JMP
+(.COMPUTE_ELEMENT)
+@THAT
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@1
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@THAT
+D=M
+@2
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@4
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@4
+M=D
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@.MAIN_LOOP_START
+0;JMP
+(.END_PROGRAM)
@@ -0,0 +1,601 @@
+@256
+D=A
+@SP
+M=D
+@NestedCall.asm.return.0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@0
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Sys.init
+0;JMP
+(NestedCall.asm.return.0)
+(Sys.init)
+@4000
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@3
+M=D
+@5000
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@4
+M=D
+@Sys.init.return.0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@0
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Sys.main
+0;JMP
+(Sys.init.return.0)
+@SP
+M=M-1
+A=M
+D=M
+@6
+M=D
+(Sys.init.LOOP)
+@Sys.init.LOOP
+0;JMP
+(Sys.main)
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@4001
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@3
+M=D
+@5001
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@4
+M=D
+@200
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@1
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@40
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@2
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@6
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@3
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@123
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Sys.main.return.1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@1
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Sys.add12
+0;JMP
+(Sys.main.return.1)
+@SP
+M=M-1
+A=M
+D=M
+@5
+M=D
+@LCL
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@1
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@2
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@3
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@4
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
+(Sys.add12)
+@4002
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@3
+M=D
+@5002
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@4
+M=D
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@12
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
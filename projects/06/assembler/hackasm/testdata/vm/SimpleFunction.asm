@@ -0,0 +1,161 @@
+(SimpleFunction.test)
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@1
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=!M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@ARG
+D=M
+@1
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
@@ -0,0 +1,591 @@
+@17
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@17
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+D=D+M
+@writeTrue.0
+D;JEQ
+D=0
+@writeFalse.0
+0;JMP
+(writeTrue.0)
+D=-1
+(writeFalse.0)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@17
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@16
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+D=D+M
+@writeTrue.1
+D;JEQ
+D=0
+@writeFalse.1
+0;JMP
+(writeTrue.1)
+D=-1
+(writeFalse.1)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@16
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@17
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+D=D+M
+@writeTrue.2
+D;JEQ
+D=0
+@writeFalse.2
+0;JMP
+(writeTrue.2)
+D=-1
+(writeFalse.2)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@892
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@891
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.3
+D;JLT
+@13
+D=M
+@sameSign.3
+D;JGE
+@signDiffer.3
+0;JMP
+(xNegative.3)
+@13
+D=M
+@sameSign.3
+D;JLT
+(signDiffer.3)
+@SP
+A=M
+D=M
+@writeTrue.3
+D;JLT
+@setFalse.3
+0;JMP
+(sameSign.3)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.3
+D;JLT
+(setFalse.3)
+D=0
+@writeFalse.3
+0;JMP
+(writeTrue.3)
+D=-1
+(writeFalse.3)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@891
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@892
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.4
+D;JLT
+@13
+D=M
+@sameSign.4
+D;JGE
+@signDiffer.4
+0;JMP
+(xNegative.4)
+@13
+D=M
+@sameSign.4
+D;JLT
+(signDiffer.4)
+@SP
+A=M
+D=M
+@writeTrue.4
+D;JLT
+@setFalse.4
+0;JMP
+(sameSign.4)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.4
+D;JLT
+(setFalse.4)
+D=0
+@writeFalse.4
+0;JMP
+(writeTrue.4)
+D=-1
+(writeFalse.4)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@891
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@891
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.5
+D;JLT
+@13
+D=M
+@sameSign.5
+D;JGE
+@signDiffer.5
+0;JMP
+(xNegative.5)
+@13
+D=M
+@sameSign.5
+D;JLT
+(signDiffer.5)
+@SP
+A=M
+D=M
+@writeTrue.5
+D;JLT
+@setFalse.5
+0;JMP
+(sameSign.5)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.5
+D;JLT
+(setFalse.5)
+D=0
+@writeFalse.5
+0;JMP
+(writeTrue.5)
+D=-1
+(writeFalse.5)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@32767
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@32766
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.6
+D;JLT
+@13
+D=M
+@sameSign.6
+D;JGE
+@signDiffer.6
+0;JMP
+(xNegative.6)
+@13
+D=M
+@sameSign.6
+D;JLT
+(signDiffer.6)
+@SP
+A=M
+D=M
+@writeTrue.6
+D;JGE
+@setFalse.6
+0;JMP
+(sameSign.6)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.6
+D;JGT
+(setFalse.6)
+D=0
+@writeFalse.6
+0;JMP
+(writeTrue.6)
+D=-1
+(writeFalse.6)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@32766
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@32767
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.7
+D;JLT
+@13
+D=M
+@sameSign.7
+D;JGE
+@signDiffer.7
+0;JMP
+(xNegative.7)
+@13
+D=M
+@sameSign.7
+D;JLT
+(signDiffer.7)
+@SP
+A=M
+D=M
+@writeTrue.7
+D;JGE
+@setFalse.7
+0;JMP
+(sameSign.7)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.7
+D;JGT
+(setFalse.7)
+D=0
+@writeFalse.7
+0;JMP
+(writeTrue.7)
+D=-1
+(writeFalse.7)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@32766
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@32766
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.8
+D;JLT
+@13
+D=M
+@sameSign.8
+D;JGE
+@signDiffer.8
+0;JMP
+(xNegative.8)
+@13
+D=M
+@sameSign.8
+D;JLT
+(signDiffer.8)
+@SP
+A=M
+D=M
+@writeTrue.8
+D;JGE
+@setFalse.8
+0;JMP
+(sameSign.8)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.8
+D;JGT
+(setFalse.8)
+D=0
+@writeFalse.8
+0;JMP
+(writeTrue.8)
+D=-1
+(writeFalse.8)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@57
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@31
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@53
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@112
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+M=-M
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+D=D&M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@82
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+D=D|M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=!M
+@SP
+A=M
+M=D
+@SP
+M=M+1
@@ -0,0 +1,80 @@
+@111
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@333
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@888
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@StaticTest.tmp.8
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@StaticTest.tmp.3
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@StaticTest.tmp.1
+M=D
+@StaticTest.tmp.3
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@StaticTest.tmp.1
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@StaticTest.tmp.8
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
@@ -0,0 +1,684 @@
+@256
+D=A
+@SP
+M=D
+@StaticsTest.asm.return.0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@0
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Sys.init
+0;JMP
+(StaticsTest.asm.return.0)
+(Class1.set)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@Class1.tmp.0
+M=D
+@ARG
+D=M
+@1
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@Class1.tmp.1
+M=D
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
+(Class1.get)
+@Class1.tmp.0
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Class1.tmp.1
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
+(Class2.set)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@Class2.tmp.0
+M=D
+@ARG
+D=M
+@1
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@Class2.tmp.1
+M=D
+@0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
+(Class2.get)
+@Class2.tmp.0
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Class2.tmp.1
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@LCL
+D=M
+@13
+M=D
+D=M
+@5
+D=D-A
+A=D
+D=M
+@14
+M=D
+@ARG
+D=M
+@0
+D=D+A
+@15
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@15
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@13
+D=M
+@1
+D=D-A
+A=D
+D=M
+@THAT
+M=D
+@13
+D=M
+@2
+D=D-A
+A=D
+D=M
+@THIS
+M=D
+@13
+D=M
+@3
+D=D-A
+A=D
+D=M
+@ARG
+M=D
+@13
+D=M
+@4
+D=D-A
+A=D
+D=M
+@LCL
+M=D
+@14
+A=M
+0;JMP
+(Sys.init)
+@6
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@8
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Sys.init.return.0
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@2
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Class1.set
+0;JMP
+(Sys.init.return.0)
+@SP
+M=M-1
+A=M
+D=M
+@5
+M=D
+@23
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@15
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Sys.init.return.1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@2
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Class2.set
+0;JMP
+(Sys.init.return.1)
+@SP
+M=M-1
+A=M
+D=M
+@5
+M=D
+@Sys.init.return.2
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@0
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Class1.get
+0;JMP
+(Sys.init.return.2)
+@Sys.init.return.3
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@0
+D=D-A
+@5
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Class2.get
+0;JMP
+(Sys.init.return.3)
+(Sys.init.WHILE)
+@Sys.init.WHILE
+0;JMP
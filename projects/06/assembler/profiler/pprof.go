@@ -0,0 +1,189 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// protobuf 编码pprof的profile.proto所需的最小protobuf写入器
+type protobuf struct {
+	bytes.Buffer
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+func (b *protobuf) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.varint(uint64(field)<<3 | 0)
+	b.varint(x)
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	var packed protobuf
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(field, packed.Bytes())
+}
+
+func (b *protobuf) message(field int, m *protobuf) {
+	b.bytes(field, m.Bytes())
+}
+
+// profile.proto中的字段编号
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+	profilePeriodType  = 11
+	profilePeriod      = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID      = 1
+	locationAddress = 3
+	locationLine    = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// pprofWriter 生成profile时的字符串表、函数和位置
+type pprofWriter struct {
+	p         *Profiler
+	profile   protobuf
+	strings   map[string]uint64
+	table     []string
+	functions map[string]uint64
+	locations map[uint16]uint64
+}
+
+func (w *pprofWriter) stringID(s string) uint64 {
+	if id, ok := w.strings[s]; ok {
+		return id
+	}
+	id := uint64(len(w.table))
+	w.strings[s] = id
+	w.table = append(w.table, s)
+	return id
+}
+
+func (w *pprofWriter) functionID(name string) uint64 {
+	if id, ok := w.functions[name]; ok {
+		return id
+	}
+	id := uint64(len(w.functions) + 1)
+	w.functions[name] = id
+	var start symbol
+	for _, function := range w.p.functions {
+		if function.name == name {
+			start = function
+			break
+		}
+	}
+	pos := w.p.lines[start.address]
+	var m protobuf
+	m.uint64(functionID, id)
+	m.uint64(functionName, w.stringID(name))
+	m.uint64(functionSystemName, w.stringID(name))
+	m.uint64(functionFilename, w.stringID(pos.File))
+	m.uint64(functionStartLine, uint64(pos.Line))
+	w.profile.message(profileFunction, &m)
+	return id
+}
+
+// locationID 每个ROM地址一个位置，行号为该指令在汇编源码中的行
+func (w *pprofWriter) locationID(address uint16) uint64 {
+	if id, ok := w.locations[address]; ok {
+		return id
+	}
+	id := uint64(len(w.locations) + 1)
+	w.locations[address] = id
+	var line protobuf
+	line.uint64(lineFunctionID, w.functionID(w.p.Function(address)))
+	line.uint64(lineLine, uint64(w.p.lines[address].Line))
+	var m protobuf
+	m.uint64(locationID, id)
+	m.uint64(locationAddress, uint64(address))
+	m.message(locationLine, &line)
+	w.profile.message(profileLocation, &m)
+	return id
+}
+
+func (w *pprofWriter) valueType(field int, typ, unit string) {
+	var m protobuf
+	m.uint64(valueTypeType, w.stringID(typ))
+	m.uint64(valueTypeUnit, w.stringID(unit))
+	w.profile.message(field, &m)
+}
+
+func (w *pprofWriter) samples(n *node) {
+	for address, count := range n.counts {
+		stack := []uint64{w.locationID(address)}
+		for caller := n; caller.parent != nil; caller = caller.parent {
+			stack = append(stack, w.locationID(caller.callsite))
+		}
+		var m protobuf
+		m.packed(sampleLocationID, stack)
+		m.packed(sampleValue, []uint64{uint64(count)})
+		w.profile.message(profileSample, &m)
+	}
+	for _, child := range n.children {
+		w.samples(child)
+	}
+}
+
+// WritePprof 以gzip压缩的pprof格式输出profile，可以用go tool pprof查看。
+// 每个样本是一个调用栈上执行的某条指令，值为执行次数
+func (p *Profiler) WritePprof(writer io.Writer) error {
+	if !p.prepared {
+		p.prepare()
+	}
+	w := &pprofWriter{
+		p:         p,
+		strings:   map[string]uint64{},
+		functions: map[string]uint64{},
+		locations: map[uint16]uint64{},
+	}
+	w.stringID("")
+	w.valueType(profileSampleType, "instructions", "count")
+	w.samples(p.root)
+	w.valueType(profilePeriodType, "instructions", "count")
+	w.profile.uint64(profilePeriod, 1)
+	for _, s := range w.table {
+		w.profile.bytes(profileStringTable, []byte(s))
+	}
+
+	gz := gzip.NewWriter(writer)
+	if _, err := gz.Write(w.profile.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
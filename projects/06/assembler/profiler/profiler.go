@@ -0,0 +1,351 @@
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"nand2tetris/06/assembler/emulator"
+	"nand2tetris/06/assembler/hackasm"
+)
+
+// startName 第一个标签之前的代码所属的函数名
+const startName = "_start"
+
+// sharedCallName VM翻译器-shared-calls时所有call共用的代码，sharedPrefix开头的标签都是这样的共享代码
+const (
+	sharedCallName = "$$call"
+	sharedPrefix   = "$$"
+)
+
+// dEqualsA D=A的编码
+const dEqualsA = 0xec10
+
+// Profiler 统计Hack程序每个ROM地址的执行次数，并按标签和VM函数汇总。
+// VM翻译器的call生成 "@F 0;JMP (Caller.return.N)"，共享call时生成
+// "@F D=A @$$call 0;JMP (Caller.return.N)"，据此找出函数入口并维护调用栈，
+// 没有这样的调用序列时每个标签都作为一个函数
+type Profiler struct {
+	counts [emulator.ROMSize]int64
+	total  int64
+
+	computer  *emulator.Computer
+	labels    map[uint16][]string
+	lines     map[uint16]hackasm.Pos
+	prepared  bool
+	entries   map[uint16]string
+	calls     map[uint16]bool
+	functions []symbol
+	enclosing []symbol
+
+	root  *node
+	stack []frame
+	// calling 正在执行共享call代码，它的调用栈已经包括被调函数
+	calling bool
+}
+
+type symbol struct {
+	name    string
+	address uint16
+}
+
+// node 调用树的节点，从根到节点的路径即调用栈
+type node struct {
+	parent   *node
+	callsite uint16
+	children map[uint16]*node
+	counts   map[uint16]int64
+}
+
+type frame struct {
+	node *node
+	ret  uint16
+}
+
+func newNode(parent *node, callsite uint16) *node {
+	return &node{
+		parent:   parent,
+		callsite: callsite,
+		children: map[uint16]*node{},
+		counts:   map[uint16]int64{},
+	}
+}
+
+// New 统计computer此后执行的每一条指令
+func New(computer *emulator.Computer) *Profiler {
+	p := &Profiler{
+		computer: computer,
+		labels:   map[uint16][]string{},
+		lines:    map[uint16]hackasm.Pos{},
+		root:     newNode(nil, 0),
+	}
+	computer.Trace = p.trace
+	return p
+}
+
+// AddProgram 记录汇编时得到的标签和每条指令的源码位置
+func (p *Profiler) AddProgram(program *hackasm.Program) {
+	address := 0
+	for _, ins := range program.Instructions {
+		if ins.Type == hackasm.L_COMMAND {
+			p.AddLabel(ins.Symbol, uint16(address))
+			continue
+		}
+		p.lines[uint16(address)] = ins.Pos
+		address += 1
+	}
+}
+
+// AddLabel 记录一个标签，用于.hack文件和符号表
+func (p *Profiler) AddLabel(name string, address uint16) {
+	p.labels[address] = append(p.labels[address], name)
+	p.prepared = false
+}
+
+// prepare 根据ROM中的调用序列找出函数入口，并按地址排序标签和函数
+func (p *Profiler) prepare() {
+	p.prepared = true
+	p.entries = map[uint16]string{}
+	p.calls = map[uint16]bool{}
+	rom := &p.computer.ROM
+	for address, names := range p.labels {
+		for _, name := range names {
+			if !strings.Contains(name, ".return.") || address < 2 {
+				continue
+			}
+			// @F 0;JMP (name)
+			target, jmp := rom[address-2], rom[address-1]
+			if target&0x8000 != 0 || jmp&0xe007 != 0xe007 {
+				continue
+			}
+			// @F D=A @$$call 0;JMP (name)
+			if p.hasLabel(target, sharedCallName) && address >= 4 && rom[address-4]&0x8000 == 0 && rom[address-3] == dEqualsA {
+				p.calls[target] = true
+				target = rom[address-4]
+			}
+			for _, entry := range p.labels[target] {
+				if !strings.Contains(entry, ".return.") {
+					p.entries[target] = entry
+				}
+			}
+		}
+	}
+
+	p.functions = p.functions[:0]
+	p.enclosing = p.enclosing[:0]
+	for address, names := range p.labels {
+		name := names[len(names)-1]
+		if entry, ok := p.entries[address]; ok {
+			name = entry
+			p.functions = append(p.functions, symbol{name, address})
+		} else if len(p.entries) == 0 || strings.HasPrefix(name, sharedPrefix) {
+			p.functions = append(p.functions, symbol{name, address})
+		}
+		p.enclosing = append(p.enclosing, symbol{name, address})
+	}
+	sortSymbols(p.functions)
+	sortSymbols(p.enclosing)
+}
+
+func (p *Profiler) hasLabel(address uint16, name string) bool {
+	for _, label := range p.labels[address] {
+		if label == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortSymbols(symbols []symbol) {
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].address < symbols[j].address
+	})
+}
+
+// lookup 地址所在的符号，即地址不大于address的最后一个符号
+func lookup(symbols []symbol, address uint16) (symbol, bool) {
+	i := sort.Search(len(symbols), func(i int) bool {
+		return symbols[i].address > address
+	})
+	if i == 0 {
+		return symbol{startName, 0}, false
+	}
+	return symbols[i-1], true
+}
+
+// Function 地址所属的函数
+func (p *Profiler) Function(address uint16) string {
+	if !p.prepared {
+		p.prepare()
+	}
+	function, _ := lookup(p.functions, address)
+	return function.name
+}
+
+// Label 地址所在的标签
+func (p *Profiler) Label(address uint16) string {
+	if !p.prepared {
+		p.prepare()
+	}
+	label, _ := lookup(p.enclosing, address)
+	return label.name
+}
+
+func (p *Profiler) trace(pc uint16) {
+	if !p.prepared {
+		p.prepare()
+	}
+	p.counts[pc]++
+	p.total++
+	current := p.root
+	if len(p.stack) > 0 {
+		current = p.stack[len(p.stack)-1].node
+	}
+	current.counts[pc]++
+
+	target := p.computer.PC
+	if target == pc+1 {
+		return
+	}
+	_, entry := p.entries[target]
+	if entry && p.calling {
+		// 共享call代码跳到函数入口，跳到共享代码时已经入栈
+		p.calling = false
+		return
+	}
+	if entry || p.calls[target] {
+		child := current.children[pc]
+		if child == nil {
+			child = newNode(current, pc)
+			current.children[pc] = child
+		}
+		p.stack = append(p.stack, frame{node: child, ret: pc + 1})
+		p.calling = p.calls[target]
+		return
+	}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].ret == target {
+			p.stack = p.stack[:i]
+			break
+		}
+	}
+}
+
+// Count address处的指令被执行的次数
+func (p *Profiler) Count(address uint16) int64 {
+	return p.counts[address]
+}
+
+// Total 执行的指令总数
+func (p *Profiler) Total() int64 {
+	return p.total
+}
+
+// Entry 平面profile中的一行。Self为在该函数或标签中执行的指令数，
+// Total为调用栈中包含该函数的指令数，即包括它调用的函数
+type Entry struct {
+	Name  string
+	Self  int64
+	Total int64
+}
+
+// Labels 按标签汇总的执行次数，从多到少排列
+func (p *Profiler) Labels() []Entry {
+	self := map[string]int64{}
+	for address, count := range p.counts {
+		if count != 0 {
+			self[p.Label(uint16(address))] += count
+		}
+	}
+	return sortEntries(self, nil)
+}
+
+// Functions 按函数汇总的执行次数，从多到少排列
+func (p *Profiler) Functions() []Entry {
+	self := map[string]int64{}
+	for address, count := range p.counts {
+		if count != 0 {
+			self[p.Function(uint16(address))] += count
+		}
+	}
+	// 调用栈上的函数是各个调用点所在的函数，加上正在执行的指令所在的函数，递归的函数只计一次
+	total := map[string]int64{}
+	onStack := map[string]int{}
+	var walk func(n *node)
+	walk = func(n *node) {
+		caller := ""
+		if n.parent != nil {
+			caller = p.Function(n.callsite)
+			onStack[caller] += 1
+		}
+		var sum int64
+		for address, count := range n.counts {
+			sum += count
+			if function := p.Function(address); onStack[function] == 0 {
+				total[function] += count
+			}
+		}
+		for function, depth := range onStack {
+			if depth > 0 {
+				total[function] += sum
+			}
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+		if caller != "" {
+			onStack[caller] -= 1
+		}
+	}
+	walk(p.root)
+	return sortEntries(self, total)
+}
+
+func sortEntries(self, total map[string]int64) []Entry {
+	entries := make([]Entry, 0, len(self))
+	for name, count := range self {
+		entries = append(entries, Entry{Name: name, Self: count, Total: total[name]})
+	}
+	for name, count := range total {
+		if _, ok := self[name]; !ok {
+			entries = append(entries, Entry{Name: name, Total: count})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Self != entries[j].Self {
+			return entries[i].Self > entries[j].Self
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// WriteFlat 输出平面profile：先按函数，再按标签
+func (p *Profiler) WriteFlat(writer io.Writer) error {
+	percent := func(count int64) float64 {
+		if p.total == 0 {
+			return 0
+		}
+		return float64(count) * 100 / float64(p.total)
+	}
+	fmt.Fprintf(writer, "// %d instructions\n", p.total)
+	fmt.Fprintf(writer, "%12s %7s %7s %12s %7s  %s\n", "self", "self%", "sum%", "total", "total%", "function")
+	var sum int64
+	for _, entry := range p.Functions() {
+		sum += entry.Self
+		fmt.Fprintf(writer, "%12d %6.2f%% %6.2f%% %12d %6.2f%%  %s\n",
+			entry.Self, percent(entry.Self), percent(sum), entry.Total, percent(entry.Total), entry.Name)
+	}
+	fmt.Fprintln(writer)
+	fmt.Fprintf(writer, "%12s %7s %7s  %s\n", "self", "self%", "sum%", "label")
+	sum = 0
+	for _, entry := range p.Labels() {
+		sum += entry.Self
+		_, err := fmt.Fprintf(writer, "%12d %6.2f%% %6.2f%%  %s\n", entry.Self, percent(entry.Self), percent(sum), entry.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
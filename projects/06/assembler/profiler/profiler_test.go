@@ -0,0 +1,118 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"nand2tetris/06/assembler/emulator"
+	"nand2tetris/06/assembler/hackasm"
+)
+
+// profileFibonacci 运行由VM翻译器从08/FunctionCalls/FibonacciElement生成的程序，递归计算fibonacci(4)，
+// 检查两种call都有的结果，返回按函数汇总的执行次数
+func profileFibonacci(t *testing.T, path string) (*Profiler, *hackasm.SymbolTable, map[string]Entry) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s err: %v", path, err)
+	}
+	defer f.Close()
+	words, table, program, err := hackasm.AssembleFile(path, f, hackasm.Options{})
+	if err != nil {
+		t.Fatalf("assemble err: %v", err)
+	}
+	c, err := emulator.NewComputer(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(c)
+	p.AddProgram(program)
+	if _, err := c.Run(100000); err != nil {
+		t.Fatalf("run err: %v", err)
+	}
+	if c.RAM[261] != 3 {
+		t.Fatalf("expect fibonacci(4) = 3, got %d", c.RAM[261])
+	}
+	if p.Total() != c.Cycles {
+		t.Errorf("expect %d instructions, got %d", c.Cycles, p.Total())
+	}
+
+	entry := uint16(table.GetAddress("Main.fibonacci"))
+	if p.Function(entry) != "Main.fibonacci" || p.Function(entry-1) == "Main.fibonacci" {
+		t.Errorf("wrong functions around Main.fibonacci: %s, %s", p.Function(entry-1), p.Function(entry))
+	}
+	if loop := uint16(table.GetAddress("Main.fibonacci.IF_FALSE")); p.Function(loop) != "Main.fibonacci" {
+		t.Errorf("expect IF_FALSE in Main.fibonacci, got %s", p.Function(loop))
+	}
+	if p.Count(entry) != 9 {
+		t.Errorf("expect 9 calls to Main.fibonacci, got %d", p.Count(entry))
+	}
+
+	functions := map[string]Entry{}
+	var self int64
+	for _, entry := range p.Functions() {
+		functions[entry.Name] = entry
+		self += entry.Self
+	}
+	if self != p.Total() {
+		t.Errorf("expect self counts to sum to %d, got %d", p.Total(), self)
+	}
+	if start := functions[startName]; start.Total != p.Total() {
+		t.Errorf("expect the bootstrap on every stack, got %d", start.Total)
+	}
+	return p, table, functions
+}
+
+func TestFibonacci(t *testing.T) {
+	path := "testdata/FibonacciElement.asm"
+	p, table, functions := profileFibonacci(t, path)
+	if entry := uint16(table.GetAddress("Main.fibonacci")); p.Function(entry-1) != startName {
+		t.Errorf("expect %s before Main.fibonacci, got %s", startName, p.Function(entry-1))
+	}
+	fib, sys := functions["Main.fibonacci"], functions["Sys.init"]
+	if fib.Total != fib.Self {
+		t.Errorf("expect recursive calls counted once, got self %d total %d", fib.Self, fib.Total)
+	}
+	if sys.Total != sys.Self+fib.Self {
+		t.Errorf("expect Sys.init total %d, got %d", sys.Self+fib.Self, sys.Total)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WritePprof(&buf); err != nil {
+		t.Fatalf("write pprof err: %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gunzip err: %v", err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("gunzip err: %v", err)
+	}
+	for _, expect := range []string{"instructions", "Main.fibonacci", "Sys.init", path} {
+		if !bytes.Contains(data, []byte(expect)) {
+			t.Errorf("expect %q in the pprof string table", expect)
+		}
+	}
+}
+
+// FibonacciElementShared.asm 同样由FibonacciElement生成，翻译时加了-shared-calls
+func TestFibonacciSharedCalls(t *testing.T) {
+	p, table, functions := profileFibonacci(t, "testdata/FibonacciElementShared.asm")
+	for _, name := range []string{"$$call", "$$return"} {
+		if function := p.Function(uint16(table.GetAddress(name))); function != name {
+			t.Errorf("expect %s in its own function, got %s", name, function)
+		}
+		if functions[name].Self == 0 {
+			t.Errorf("expect %s executed", name)
+		}
+	}
+	// Main.fibonacci调用自己时执行的共享代码也在它的调用栈中
+	fib, sys := functions["Main.fibonacci"], functions["Sys.init"]
+	if fib.Total <= fib.Self || fib.Total >= sys.Total {
+		t.Errorf("expect Main.fibonacci total between self %d and Sys.init total %d, got %d", fib.Self, sys.Total, fib.Total)
+	}
+}
@@ -0,0 +1,313 @@
+@256
+D=A
+@SP
+M=D
+@FibonacciElement.asm.return.0
+D=A
+@R14
+M=D
+@0
+D=A
+@R15
+M=D
+@Sys.init
+D=A
+@$$call
+0;JMP
+(FibonacciElement.asm.return.0)
+($$end)
+@$$end
+0;JMP
+($$call)
+@R13
+M=D
+@R14
+D=M
+@SP
+A=M
+M=D
+@LCL
+D=M
+@SP
+AM=M+1
+M=D
+@ARG
+D=M
+@SP
+AM=M+1
+M=D
+@THIS
+D=M
+@SP
+AM=M+1
+M=D
+@THAT
+D=M
+@SP
+AM=M+1
+M=D
+@SP
+MD=M+1
+@LCL
+M=D
+@5
+D=D-A
+@R15
+D=D-M
+@ARG
+M=D
+@R13
+A=M
+0;JMP
+($$return)
+@LCL
+D=M
+@5
+A=D-A
+D=M
+@R14
+M=D
+@SP
+AM=M-1
+D=M
+@ARG
+A=M
+M=D
+@ARG
+D=M+1
+@SP
+M=D
+@LCL
+AM=M-1
+D=M
+@THAT
+M=D
+@LCL
+AM=M-1
+D=M
+@THIS
+M=D
+@LCL
+AM=M-1
+D=M
+@ARG
+M=D
+@LCL
+AM=M-1
+D=M
+@LCL
+M=D
+@R14
+A=M
+0;JMP
+(Main.fibonacci)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@2
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@13
+M=D
+@SP
+M=M-1
+A=M
+D=M
+@xNegative.0
+D;JLT
+@13
+D=M
+@sameSign.0
+D;JGE
+@signDiffer.0
+0;JMP
+(xNegative.0)
+@13
+D=M
+@sameSign.0
+D;JLT
+(signDiffer.0)
+@SP
+A=M
+D=M
+@writeTrue.0
+D;JLT
+@setFalse.0
+0;JMP
+(sameSign.0)
+@13
+D=M
+@SP
+A=M
+D=M-D
+@writeTrue.0
+D;JLT
+(setFalse.0)
+D=0
+@writeFalse.0
+0;JMP
+(writeTrue.0)
+D=-1
+(writeFalse.0)
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=M
+@Main.fibonacci.IF_TRUE
+D;JNE
+@Main.fibonacci.IF_FALSE
+0;JMP
+(Main.fibonacci.IF_TRUE)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@$$return
+0;JMP
+(Main.fibonacci.IF_FALSE)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@2
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@Main.fibonacci.return.0
+D=A
+@R14
+M=D
+@1
+D=A
+@R15
+M=D
+@Main.fibonacci
+D=A
+@$$call
+0;JMP
+(Main.fibonacci.return.0)
+@ARG
+D=M
+@0
+D=D+A
+A=D
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+M=M-1
+A=M
+D=-M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@Main.fibonacci.return.1
+D=A
+@R14
+M=D
+@1
+D=A
+@R15
+M=D
+@Main.fibonacci
+D=A
+@$$call
+0;JMP
+(Main.fibonacci.return.1)
+@SP
+M=M-1
+A=M
+D=M
+@SP
+M=M-1
+A=M
+M=D+M
+@SP
+M=M+1
+@$$return
+0;JMP
+(Sys.init)
+@4
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Sys.init.return.2
+D=A
+@R14
+M=D
+@1
+D=A
+@R15
+M=D
+@Main.fibonacci
+D=A
+@$$call
+0;JMP
+(Sys.init.return.2)
+(Sys.init.WHILE)
+@Sys.init.WHILE
+0;JMP
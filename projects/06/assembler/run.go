@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"nand2tetris/06/assembler/debugger"
+	"nand2tetris/06/assembler/emulator"
+	"nand2tetris/06/assembler/hackasm"
+	"nand2tetris/06/assembler/profiler"
+	"nand2tetris/06/assembler/tst"
+)
+
+// doEmulate 在模拟器中执行源文件，.asm文件会先经过汇编
+func doEmulate(writer io.Writer) error {
+	words, program, err := loadSource(*source)
+	if err != nil {
+		return err
+	}
+	computer, err := emulator.NewComputer(words)
+	if err != nil {
+		return err
+	}
+	if err := loadKeyScript(computer); err != nil {
+		return err
+	}
+	var prof *profiler.Profiler
+	if *flatProfile != "" || *pprofProfile != "" {
+		if prof, err = newProfiler(computer, program); err != nil {
+			return err
+		}
+	}
+	if *breakpoints != "" {
+		for _, bp := range strings.Split(*breakpoints, ",") {
+			address, err := strconv.ParseUint(strings.TrimSpace(bp), 10, 16)
+			if err != nil {
+				return fmt.Errorf("invalid breakpoint '%s'", bp)
+			}
+			computer.SetBreakpoint(uint16(address))
+		}
+	}
+
+	var recorder *emulator.Recorder
+	var reason emulator.StopReason
+	if *screenGIF != "" {
+		recorder = emulator.NewRecorder(*gifEvery, 4)
+		reason, err = recorder.Run(computer, *maxCycles)
+	} else {
+		reason, err = computer.Run(*maxCycles)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeScreens(computer, recorder); err != nil {
+		return err
+	}
+	if err := writeProfiles(prof); err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "// stopped: %s, cycles: %d, PC: %d, A: %d, D: %d\n",
+		reason, computer.Cycles, computer.PC, int16(computer.A), int16(computer.D))
+
+	if *dumpRanges == "" {
+		return nil
+	}
+	for _, r := range strings.Split(*dumpRanges, ",") {
+		from, to, err := parseRange(r)
+		if err != nil {
+			return err
+		}
+		if err := computer.DumpRAM(writer, from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doDebug 在标准输入输出上运行调试器，.asm文件可以使用符号和源码行
+func doDebug(reader io.Reader) error {
+	var d *debugger.Debugger
+	if strings.HasSuffix(*source, ".asm") {
+		words, table, program, err := hackasm.AssembleFile(*source, reader, assembleOptions())
+		if err != nil {
+			return err
+		}
+		d, err = debugger.New(words, program, table)
+		if err != nil {
+			return err
+		}
+	} else {
+		words, err := emulator.LoadHack(reader)
+		if err != nil {
+			return err
+		}
+		d, err = debugger.New(words, nil, nil)
+		if err != nil {
+			return err
+		}
+	}
+	d.MaxCycles = *maxCycles
+	if err := loadKeyScript(d.Computer()); err != nil {
+		return err
+	}
+	if *breakpoints != "" {
+		for _, bp := range strings.Split(*breakpoints, ",") {
+			if _, err := d.Exec("break " + strings.TrimSpace(bp)); err != nil {
+				return err
+			}
+		}
+	}
+	return d.Run(os.Stdin, os.Stdout)
+}
+
+// loadKeyScript 按-keys设置按键脚本
+func loadKeyScript(computer *emulator.Computer) error {
+	if *keyScript == "" {
+		return nil
+	}
+	f, err := os.Open(*keyScript)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	script, err := emulator.ParseKeyScript(*keyScript, f)
+	if err != nil {
+		return err
+	}
+	computer.SetKeyScript(script)
+	return nil
+}
+
+// newProfiler 统计computer执行的指令，.hack文件的标签来自-m指定的符号表
+func newProfiler(computer *emulator.Computer, program *hackasm.Program) (*profiler.Profiler, error) {
+	prof := profiler.New(computer)
+	if program != nil {
+		prof.AddProgram(program)
+		return prof, nil
+	}
+	if *symbolMapPath == "" {
+		return prof, nil
+	}
+	f, err := os.Open(*symbolMapPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	symbols, err := hackasm.ReadSymbolMap(*symbolMapPath, f)
+	if err != nil {
+		return nil, err
+	}
+	for address, labels := range symbols.Labels() {
+		for _, label := range labels {
+			prof.AddLabel(label, uint16(address))
+		}
+	}
+	return prof, nil
+}
+
+// writeProfiles 按-prof和-pprof输出profile
+func writeProfiles(prof *profiler.Profiler) error {
+	if prof == nil {
+		return nil
+	}
+	if *flatProfile != "" {
+		var buf bytes.Buffer
+		if err := prof.WriteFlat(&buf); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*flatProfile, buf.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	if *pprofProfile != "" {
+		var buf bytes.Buffer
+		if err := prof.WritePprof(&buf); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*pprofProfile, buf.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeScreens 按-png和-gif输出屏幕
+func writeScreens(computer *emulator.Computer, recorder *emulator.Recorder) error {
+	if *screenPNG != "" {
+		var buf bytes.Buffer
+		if err := computer.WriteScreenPNG(&buf); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*screenPNG, buf.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	if recorder != nil {
+		var buf bytes.Buffer
+		if err := recorder.WriteGIF(&buf); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*screenGIF, buf.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRange 解析 "n" 或者 "from-to" 形式的地址范围
+func parseRange(r string) (int, int, error) {
+	r = strings.TrimSpace(r)
+	bounds := strings.SplitN(r, "-", 2)
+	from, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range '%s'", r)
+	}
+	to := from
+	if len(bounds) == 2 {
+		to, err = strconv.Atoi(bounds[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid range '%s'", r)
+		}
+	}
+	return from, to, nil
+}
+
+// doScript 执行.tst脚本并与.cmp比较
+func doScript(writer io.Writer) error {
+	runner := tst.NewRunner(loadProgram)
+	runner.Echo = writer
+	if err := runner.RunFile(*source); err != nil {
+		return err
+	}
+	fmt.Fprintln(writer, "End of script - Comparison ended successfully")
+	return nil
+}
+
+// loadProgram 读取.hack文件，.asm文件会先经过汇编。
+// .hack文件不存在时，尝试汇编同目录下同名（忽略大小写）的.asm文件
+func loadProgram(path string) ([]uint16, error) {
+	words, _, err := loadSource(path)
+	return words, err
+}
+
+// loadSource 与loadProgram相同，.asm文件同时返回解析后的程序，.hack文件返回nil
+func loadSource(path string) ([]uint16, *hackasm.Program, error) {
+	if strings.HasSuffix(path, ".hack") {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if asmPath, ok := findSiblingAsm(path); ok {
+				path = asmPath
+			}
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".asm") {
+		words, _, program, err := hackasm.AssembleFile(path, f, assembleOptions())
+		return words, program, err
+	}
+	words, err := emulator.LoadHack(f)
+	return words, nil, err
+}
+
+func findSiblingAsm(hackPath string) (string, bool) {
+	dir, name := filepath.Split(hackPath)
+	base := strings.TrimSuffix(name, ".hack") + ".asm"
+	entries, err := ioutil.ReadDir(filepath.Clean(dir + "."))
+	if err != nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), base) {
+			return filepath.Join(dir, entry.Name()), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+
+	"nand2tetris/06/assembler/tst"
+)
+
+func TestMultScript(t *testing.T) {
+	runner := tst.NewRunner(loadProgram)
+	runner.OutputDir = t.TempDir()
+	if err := runner.RunFile("../../04/mult/Mult.tst"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package tst
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output output-file、compare-to和output-list的实现，CPU模拟器和VM模拟器的脚本共用。
+// 每写入一行就与compare-to文件的对应行比较
+type Output struct {
+	// Dir 不为空时output-file写到该目录，否则写到给定的路径
+	Dir string
+
+	file    *os.File
+	writer  *bufio.Writer
+	compare []string
+	columns []Column
+	line    int
+}
+
+// Reset 关闭输出文件，清除比较文件和输出列，用于开始执行新的脚本
+func (o *Output) Reset() {
+	o.Close()
+	o.compare = nil
+	o.columns = nil
+	o.line = 0
+}
+
+// Open 执行output-file命令
+func (o *Output) Open(path string) error {
+	o.Close()
+	if o.Dir != "" {
+		path = filepath.Join(o.Dir, filepath.Base(path))
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	o.file = f
+	o.writer = bufio.NewWriter(f)
+	return nil
+}
+
+func (o *Output) Close() {
+	if o.file != nil {
+		o.writer.Flush()
+		o.file.Close()
+		o.file = nil
+		o.writer = nil
+	}
+}
+
+// ReadCompare 执行compare-to命令
+func (o *Output) ReadCompare(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	o.compare = nil
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		o.compare = append(o.compare, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// SetColumns 执行output-list命令，并写入表头
+func (o *Output) SetColumns(specs []string) error {
+	o.columns = o.columns[:0]
+	for _, spec := range specs {
+		column, err := ParseColumn(spec)
+		if err != nil {
+			return err
+		}
+		o.columns = append(o.columns, column)
+	}
+	cells := make([]string, 0, len(o.columns))
+	for _, column := range o.columns {
+		cells = append(cells, column.Header())
+	}
+	return o.writeLine("|" + strings.Join(cells, "|") + "|")
+}
+
+// Write 执行output命令，value返回每一列格式化之前的值
+func (o *Output) Write(value func(column Column) (string, error)) error {
+	cells := make([]string, 0, len(o.columns))
+	for _, column := range o.columns {
+		v, err := value(column)
+		if err != nil {
+			return err
+		}
+		cells = append(cells, column.FormatValue(v))
+	}
+	return o.writeLine("|" + strings.Join(cells, "|") + "|")
+}
+
+// writeLine 写入一行输出并立即与compare-to文件的对应行比较
+func (o *Output) writeLine(line string) error {
+	if o.writer != nil {
+		o.writer.WriteString(line + "\n")
+	}
+	o.line += 1
+	if o.compare == nil {
+		return nil
+	}
+	if o.line > len(o.compare) {
+		return fmt.Errorf("comparison failure at line %d: compare file has only %d lines", o.line, len(o.compare))
+	}
+	expect := o.compare[o.line-1]
+	if !matchLine(expect, line) {
+		return fmt.Errorf("comparison failure at line %d:\nexpect: %s\n   got: %s", o.line, expect, line)
+	}
+	return nil
+}
+
+// matchLine 忽略行尾空白，.cmp中的'*'匹配任意字符
+func matchLine(expect, actual string) bool {
+	expect = strings.TrimRight(expect, " \t\r")
+	actual = strings.TrimRight(actual, " \t\r")
+	if len(expect) != len(actual) {
+		return false
+	}
+	for i := 0; i < len(expect); i++ {
+		if expect[i] != '*' && expect[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
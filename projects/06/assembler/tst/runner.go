@@ -0,0 +1,294 @@
+package tst
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"nand2tetris/06/assembler/emulator"
+	"nand2tetris/06/assembler/hackasm"
+)
+
+// Loader 读取load命令指定的程序，返回机器码
+type Loader func(path string) ([]uint16, error)
+
+// LoadProgramFile 默认的Loader，读取.hack文件，.asm文件会先经过汇编
+func LoadProgramFile(path string) ([]uint16, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".asm") {
+		words, _, err := hackasm.Assemble(f)
+		return words, err
+	}
+	return emulator.LoadHack(f)
+}
+
+// Runner 执行CPU模拟器的.tst脚本，并将输出与.cmp文件逐行比较。
+// 除了CPU模拟器的语法外，也支持以 load Computer.hdl 开头的Computer芯片脚本
+type Runner struct {
+	// OutputDir 不为空时output-file写到该目录，否则写到脚本所在目录
+	OutputDir string
+	// Echo echo命令的输出，为nil时忽略
+	Echo io.Writer
+
+	dir      string
+	loader   Loader
+	computer *emulator.Computer
+	chipMode bool
+	reset    bool
+	time     int
+	ticked   bool
+	out      Output
+}
+
+func NewRunner(loader Loader) *Runner {
+	if loader == nil {
+		loader = LoadProgramFile
+	}
+	return &Runner{
+		loader: loader,
+	}
+}
+
+// RunFile 执行path指定的脚本，脚本中的相对路径以脚本所在目录为准
+func (r *Runner) RunFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Run(path, f)
+}
+
+func (r *Runner) Run(filename string, reader io.Reader) error {
+	statements, err := Parse(filename, reader)
+	if err != nil {
+		return err
+	}
+	r.dir = filepath.Dir(filename)
+	r.computer, _ = emulator.NewComputer(nil)
+	r.chipMode = false
+	r.reset = false
+	r.time = 0
+	r.ticked = false
+	r.out.Reset()
+	r.out.Dir = r.OutputDir
+	defer r.out.Close()
+
+	if err := r.exec(statements); err != nil {
+		return fmt.Errorf("%s:%v", filename, err)
+	}
+	return nil
+}
+
+// Computer 脚本使用的模拟器，脚本执行完后可以用来检查状态
+func (r *Runner) Computer() *emulator.Computer {
+	return r.computer
+}
+
+func (r *Runner) exec(statements []Statement) error {
+	for _, stmt := range statements {
+		if err := r.execStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) execStatement(stmt Statement) error {
+	args := stmt.Args
+	wrap := func(err error) error {
+		if err == nil {
+			return nil
+		}
+		return fmt.Errorf("%d: %v", stmt.Line, err)
+	}
+	expectArgs := func(n int) error {
+		if len(args) != n {
+			return wrap(fmt.Errorf("%s expects %d arguments, got %d", args[0], n-1, len(args)-1))
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "load":
+		if err := expectArgs(2); err != nil {
+			return err
+		}
+		return wrap(r.load(args[1]))
+	case "ROM32K":
+		if len(args) != 3 || args[1] != "load" {
+			return wrap(fmt.Errorf("expect 'ROM32K load <file>'"))
+		}
+		return wrap(r.loadProgram(args[2]))
+	case "output-file":
+		if err := expectArgs(2); err != nil {
+			return err
+		}
+		return wrap(r.out.Open(r.path(args[1])))
+	case "compare-to":
+		if err := expectArgs(2); err != nil {
+			return err
+		}
+		return wrap(r.out.ReadCompare(r.path(args[1])))
+	case "output-list":
+		return wrap(r.out.SetColumns(args[1:]))
+	case "set":
+		if err := expectArgs(3); err != nil {
+			return err
+		}
+		value, err := ParseValue(args[2])
+		if err != nil {
+			return wrap(err)
+		}
+		return wrap(r.set(args[1], value))
+	case "repeat":
+		if len(args) == 1 {
+			return wrap(fmt.Errorf("repeat without a count never ends, not supported"))
+		}
+		if err := expectArgs(2); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return wrap(fmt.Errorf("invalid repeat count '%s'", args[1]))
+		}
+		for i := 0; i < n; i++ {
+			if err := r.exec(stmt.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "tick":
+		r.ticked = true
+		return nil
+	case "tock":
+		r.ticked = false
+		return wrap(r.clock())
+	case "ticktock":
+		return wrap(r.clock())
+	case "output":
+		return wrap(r.out.Write(r.columnValue))
+	case "echo":
+		if r.Echo != nil {
+			fmt.Fprintln(r.Echo, strings.Trim(strings.Join(args[1:], " "), `"`))
+		}
+		return nil
+	case "clear-echo":
+		return nil
+	}
+	return wrap(fmt.Errorf("unsupported command '%s'", args[0]))
+}
+
+func (r *Runner) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(r.dir, name)
+}
+
+func (r *Runner) load(name string) error {
+	if strings.HasSuffix(name, ".hdl") {
+		if name != "Computer.hdl" {
+			return fmt.Errorf("only Computer.hdl is supported, got '%s'", name)
+		}
+		r.chipMode = true
+		return nil
+	}
+	return r.loadProgram(name)
+}
+
+func (r *Runner) loadProgram(name string) error {
+	program, err := r.loader(r.path(name))
+	if err != nil {
+		return err
+	}
+	return r.computer.Load(program)
+}
+
+// clock 执行一个时钟周期，reset为1时执行完当前指令后PC归零
+func (r *Runner) clock() error {
+	if err := r.computer.Step(); err != nil {
+		return err
+	}
+	if r.reset {
+		r.computer.PC = 0
+	}
+	r.time += 1
+	return nil
+}
+
+var variablePattern = regexp.MustCompile(`^([A-Za-z0-9]+)(?:\[(\d*)\])?$`)
+
+// variable 返回脚本变量对应的寄存器或内存
+func (r *Runner) variable(name string) (*uint16, error) {
+	m := variablePattern.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("unknown variable '%s'", name)
+	}
+	c := r.computer
+	switch m[1] {
+	case "A", "ARegister":
+		return &c.A, nil
+	case "D", "DRegister":
+		return &c.D, nil
+	case "PC":
+		return &c.PC, nil
+	case "RAM", "RAM16K", "ROM", "ROM32K":
+		index, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("expect index for '%s'", name)
+		}
+		if m[1] == "ROM" || m[1] == "ROM32K" {
+			if index >= emulator.ROMSize {
+				return nil, fmt.Errorf("ROM index %d out of range", index)
+			}
+			return &c.ROM[index], nil
+		}
+		if index >= emulator.RAMSize {
+			return nil, fmt.Errorf("RAM index %d out of range", index)
+		}
+		return &c.RAM[index], nil
+	}
+	return nil, fmt.Errorf("unknown variable '%s'", name)
+}
+
+func (r *Runner) set(name string, value uint16) error {
+	if name == "reset" {
+		r.reset = value != 0
+		return nil
+	}
+	v, err := r.variable(name)
+	if err != nil {
+		return err
+	}
+	*v = value
+	return nil
+}
+
+func (r *Runner) columnValue(column Column) (string, error) {
+	switch column.Name {
+	case "time":
+		if r.ticked {
+			return strconv.Itoa(r.time) + "+", nil
+		}
+		return strconv.Itoa(r.time), nil
+	case "reset":
+		if r.reset {
+			return column.Format16(1), nil
+		}
+		return column.Format16(0), nil
+	}
+	v, err := r.variable(column.Name)
+	if err != nil {
+		return "", err
+	}
+	return column.Format16(*v), nil
+}
@@ -0,0 +1,49 @@
+package tst
+
+import (
+	"testing"
+)
+
+func TestOutputColumn(t *testing.T) {
+	cases := []struct {
+		spec   string
+		value  string
+		header string
+		cell   string
+	}{
+		{"RAM[0]%D2.6.2", "-1", "  RAM[0]  ", "      -1  "},
+		{"time%S1.4.1", "12+", " time ", " 12+  "},
+		{"reset%B2.1.2", "1", "reset", "  1  "},
+		{"inM%D0.6.0", "0", " inM  ", "     0"},
+		{"DRegister[]%D1.6.1", "5", "DRegiste", "      5 "},
+	}
+	for _, c := range cases {
+		column, err := ParseColumn(c.spec)
+		if err != nil {
+			t.Fatalf("parse %s err: %v", c.spec, err)
+		}
+		if got := column.Header(); got != c.header {
+			t.Errorf("%s header: expect '%s', got '%s'", c.spec, c.header, got)
+		}
+		if got := column.FormatValue(c.value); got != c.cell {
+			t.Errorf("%s cell: expect '%s', got '%s'", c.spec, c.cell, got)
+		}
+	}
+}
+
+func TestComputerScripts(t *testing.T) {
+	for _, script := range []string{
+		"ComputerAdd.tst",
+		"ComputerAdd-external.tst",
+		"ComputerMax.tst",
+		"ComputerMax-external.tst",
+		"ComputerRect.tst",
+		"ComputerRect-external.tst",
+	} {
+		runner := NewRunner(nil)
+		runner.OutputDir = t.TempDir()
+		if err := runner.RunFile("../../../05/" + script); err != nil {
+			t.Errorf("run %s err: %v", script, err)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package tst
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Statement .tst脚本中的一条命令，repeat和while命令的循环体放在Body中
+type Statement struct {
+	Line int
+	Args []string
+	Body []Statement
+}
+
+type token struct {
+	val  string
+	line int
+}
+
+// Parse 解析.tst脚本
+func Parse(filename string, reader io.Reader) ([]Statement, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", filename, err)
+	}
+	statements, rest, err := parseStatements(tokens, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", filename, err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%s:%d: unexpected '%s'", filename, rest[0].line, rest[0].val)
+	}
+	return statements, nil
+}
+
+// tokenize 将脚本拆分为单词，',' ';' '{' '}'单独作为一个token，忽略所有注释
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	reader := bufio.NewReader(strings.NewReader(src))
+	line := 1
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, token{val: string(word), line: line})
+			word = word[:0]
+		}
+	}
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+		switch {
+		case r == '/' && peek(reader) == '/':
+			flush()
+			for r != '\n' && err == nil {
+				r, _, err = reader.ReadRune()
+			}
+			line += 1
+		case r == '/' && peek(reader) == '*':
+			flush()
+			reader.ReadRune()
+			prev := ' '
+			for {
+				r, _, err = reader.ReadRune()
+				if err != nil {
+					return nil, fmt.Errorf("%d: unterminated comment", line)
+				}
+				if r == '\n' {
+					line += 1
+				}
+				if prev == '*' && r == '/' {
+					break
+				}
+				prev = r
+			}
+		case r == '"':
+			flush()
+			word = append(word, r)
+			for {
+				r, _, err = reader.ReadRune()
+				if err != nil || r == '\n' {
+					return nil, fmt.Errorf("%d: unterminated string", line)
+				}
+				word = append(word, r)
+				if r == '"' {
+					break
+				}
+			}
+			flush()
+		case r == ',' || r == ';' || r == '{' || r == '}':
+			flush()
+			tokens = append(tokens, token{val: string(r), line: line})
+		case unicode.IsSpace(r):
+			flush()
+			if r == '\n' {
+				line += 1
+			}
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+	return tokens, nil
+}
+
+func peek(reader *bufio.Reader) rune {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return 0
+	}
+	reader.UnreadRune()
+	return r
+}
+
+// parseStatements 解析命令序列，inBlock为true时遇到'}'返回
+func parseStatements(tokens []token, inBlock bool) ([]Statement, []token, error) {
+	var statements []Statement
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		switch tok.val {
+		case ",", ";":
+			tokens = tokens[1:]
+			continue
+		case "}":
+			if !inBlock {
+				return nil, nil, fmt.Errorf("%d: unexpected '}'", tok.line)
+			}
+			return statements, tokens[1:], nil
+		case "{":
+			return nil, nil, fmt.Errorf("%d: unexpected '{'", tok.line)
+		}
+
+		stmt := Statement{Line: tok.line}
+		for len(tokens) > 0 && !isSeparator(tokens[0].val) {
+			stmt.Args = append(stmt.Args, tokens[0].val)
+			tokens = tokens[1:]
+		}
+		if stmt.Args[0] == "repeat" || stmt.Args[0] == "while" {
+			if len(tokens) == 0 || tokens[0].val != "{" {
+				return nil, nil, fmt.Errorf("%d: expect '{' after %s", tok.line, stmt.Args[0])
+			}
+			body, rest, err := parseStatements(tokens[1:], true)
+			if err != nil {
+				return nil, nil, err
+			}
+			stmt.Body = body
+			tokens = rest
+		}
+		statements = append(statements, stmt)
+	}
+	if inBlock {
+		return nil, nil, fmt.Errorf("unexpected end of script, missing '}'")
+	}
+	return statements, tokens, nil
+}
+
+func isSeparator(val string) bool {
+	return val == "," || val == ";" || val == "{" || val == "}"
+}
+
+// Column output-list中的一列，如 RAM[0]%D2.6.2
+type Column struct {
+	// Name 要输出的变量
+	Name     string
+	format   byte
+	padLeft  int
+	length   int
+	padRight int
+}
+
+func ParseColumn(spec string) (Column, error) {
+	column := Column{format: 'D', padLeft: 1, length: 6, padRight: 1}
+	index := strings.IndexByte(spec, '%')
+	if index == -1 {
+		column.Name = spec
+		return column, nil
+	}
+	column.Name = spec[:index]
+	fmtSpec := spec[index+1:]
+	if len(fmtSpec) == 0 {
+		return column, fmt.Errorf("invalid output format '%s'", spec)
+	}
+	column.format = fmtSpec[0]
+	if !strings.ContainsRune("DBXS", rune(column.format)) {
+		return column, fmt.Errorf("invalid output format '%s'", spec)
+	}
+	parts := strings.Split(fmtSpec[1:], ".")
+	if len(parts) != 3 {
+		return column, fmt.Errorf("invalid output format '%s'", spec)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return column, fmt.Errorf("invalid output format '%s'", spec)
+		}
+		nums[i] = n
+	}
+	column.padLeft, column.length, column.padRight = nums[0], nums[1], nums[2]
+	return column, nil
+}
+
+func (c Column) width() int {
+	return c.padLeft + c.length + c.padRight
+}
+
+// Header 列名居中显示，超出宽度时截断
+func (c Column) Header() string {
+	width := c.width()
+	name := c.Name
+	if len(name) > width {
+		return name[:width]
+	}
+	left := (width - len(name)) / 2
+	return strings.Repeat(" ", left) + name + strings.Repeat(" ", width-len(name)-left)
+}
+
+// FormatValue 十进制右对齐，字符串左对齐，二进制和十六进制取低位并补0
+func (c Column) FormatValue(value string) string {
+	if len(value) > c.length {
+		value = value[len(value)-c.length:]
+	}
+	var field string
+	if c.format == 'S' {
+		field = value + strings.Repeat(" ", c.length-len(value))
+	} else {
+		field = strings.Repeat(" ", c.length-len(value)) + value
+	}
+	return strings.Repeat(" ", c.padLeft) + field + strings.Repeat(" ", c.padRight)
+}
+
+// Format16 按列的格式输出16位的值，十进制时为有符号数
+func (c Column) Format16(value uint16) string {
+	switch c.format {
+	case 'B':
+		return fmt.Sprintf("%0*b", c.length, value)
+	case 'X':
+		return fmt.Sprintf("%0*X", c.length, value)
+	}
+	return strconv.Itoa(int(int16(value)))
+}
+
+// ParseValue 解析set命令的值，支持 %B %X %D 前缀以及负数
+func ParseValue(val string) (uint16, error) {
+	base := 10
+	digits := val
+	if strings.HasPrefix(val, "%") && len(val) > 1 {
+		switch val[1] {
+		case 'B':
+			base = 2
+		case 'X':
+			base = 16
+		case 'D':
+			base = 10
+		default:
+			return 0, fmt.Errorf("invalid value '%s'", val)
+		}
+		digits = val[2:]
+	}
+	n, err := strconv.ParseInt(digits, base, 32)
+	if err != nil || n < -32768 || n > 65535 {
+		return 0, fmt.Errorf("invalid value '%s'", val)
+	}
+	return uint16(n), nil
+}
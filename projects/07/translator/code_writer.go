@@ -9,12 +9,47 @@ import (
 	"strings"
 )
 
+// Backend 把VM命令翻译为Hack汇编的代码生成器
+type Backend interface {
+	SetFileName(filename string)
+	WriteInit()
+	WriteCommand(cmd Command)
+	WriteRawFile(path string)
+	SetSharedCalls(shared bool)
+	WriteSharedRoutines()
+	// Instructions 已经写入的指令数，不包括标签
+	Instructions() int
+	Close()
+	setClass(class string)
+}
+
+// backends 按-backend的名字创建代码生成器
+var backends = map[string]func(writer io.Writer, path string) Backend{
+	"reference": func(writer io.Writer, path string) Backend { return newCodeWriter(writer, path) },
+	"optimized": func(writer io.Writer, path string) Backend { return newOptimizingWriter(writer, path) },
+}
+
+// NewBackend 创建名为name的代码生成器，写入path
+func NewBackend(name string, path string) (Backend, error) {
+	newBackend, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend '%s', expect reference or optimized", name)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return newBackend(file, path), nil
+}
+
 type CodeWriter struct {
 	bufWriter                *bufio.Writer
 	jmpFlagCounter           int64
 	callReturnAddressCounter int64
 	filename                 string
 	curFuncName              string
+	instructions             int
+	sharedCalls              bool
 }
 
 func NewCodeWriter(path string) *CodeWriter {
@@ -22,12 +57,15 @@ func NewCodeWriter(path string) *CodeWriter {
 	if err != nil {
 		panic(err)
 	}
-	_, filename := filepath.Split(path)
-	codeWriter := &CodeWriter{
-		bufWriter: bufio.NewWriter(file),
-		filename:  filepath.Base(filename),
+	return newCodeWriter(file, path)
+}
+
+// newCodeWriter 将代码写入writer，path只用于静态变量的名字
+func newCodeWriter(writer io.Writer, path string) *CodeWriter {
+	return &CodeWriter{
+		bufWriter: bufio.NewWriter(writer),
+		filename:  filepath.Base(path),
 	}
-	return codeWriter
 }
 
 func (w *CodeWriter) SetFileName(filename string) {
@@ -40,6 +78,37 @@ func (w *CodeWriter) SetFileName(filename string) {
 	w.filename = filepath.Base(filename)
 }
 
+// setClass 之后的静态变量属于class，不切换输出文件
+func (w *CodeWriter) setClass(class string) {
+	w.filename = class + ".tmp"
+}
+
+func (w *CodeWriter) Instructions() int {
+	return w.instructions
+}
+
+// WriteCommand 写入解析得到的一条命令，注释不产生代码
+func (w *CodeWriter) WriteCommand(cmd Command) {
+	switch cmd.commandType {
+	case C_ARITHMETIC:
+		w.WriteArithmetic(cmd.Arg1)
+	case C_PUSH, C_POP:
+		w.WritePushPop(cmd.commandType, cmd.Arg1, cmd.Arg2)
+	case C_LABEL:
+		w.WriteLabel(cmd.Arg1)
+	case C_GOTO:
+		w.WriteGoto(cmd.Arg1)
+	case C_IF:
+		w.WriteIf(cmd.Arg1)
+	case C_FUNCTION:
+		w.WriteFunction(cmd.Arg1, cmd.Arg2)
+	case C_RETURN:
+		w.WriteReturn()
+	case C_CALL:
+		w.WriteCall(cmd.Arg1, int32(cmd.Arg2))
+	}
+}
+
 func (w *CodeWriter) WriteArithmetic(command string) {
 	switch command {
 	case "add":
@@ -113,7 +182,16 @@ func (w *CodeWriter) WriteIf(label string) {
 }
 
 func (w *CodeWriter) WriteCall(funcName string, n int32) {
-	returnAddressLabel := w.getCurFuncName() + ".return." + w.getCallReturnAddressCount()
+	// 函数之外的call用文件名区分，例如引导代码和各个文件开头的call
+	caller := w.getCurFuncName()
+	if caller == "" {
+		caller = w.filename
+	}
+	returnAddressLabel := caller + ".return." + w.getCallReturnAddressCount()
+	if w.sharedCalls {
+		w.writeSharedCall(funcName, n, returnAddressLabel)
+		return
+	}
 	w.writeLine(pushValue(returnAddressLabel))
 	w.writeLine(pushRegSegment("LCL"))
 	w.writeLine(pushRegSegment("ARG"))
@@ -144,6 +222,10 @@ func (w *CodeWriter) WriteCall(funcName string, n int32) {
 }
 
 func (w *CodeWriter) WriteReturn() {
+	if w.sharedCalls {
+		w.writeJmp(sharedReturnLabel)
+		return
+	}
 	// FRAME = LCL
 	w.writeLine("@LCL")
 	w.writeLine("D=M")
@@ -180,6 +262,109 @@ func (w *CodeWriter) WriteReturn() {
 
 }
 
+// 共享的call和return代码的标签，profiler根据sharedCallLabel识别调用
+const (
+	sharedCallLabel   = "$$call"
+	sharedReturnLabel = "$$return"
+	sharedEndLabel    = "$$end"
+)
+
+// SetSharedCalls 打开后每个call和return只跳转到WriteSharedRoutines写入的共享代码，
+// 而不是在原地展开，程序变小但是每次调用多执行几条指令
+func (w *CodeWriter) SetSharedCalls(shared bool) {
+	w.sharedCalls = shared
+}
+
+// writeSharedCall 在R14中放返回地址，R15中放参数个数，D中放被调函数，然后跳到$$call。
+// 最后是 "@F D=A @$$call 0;JMP (返回地址)"，profiler据此找出函数入口
+func (w *CodeWriter) writeSharedCall(funcName string, n int32, returnAddressLabel string) {
+	w.writeLine(strings.Join([]string{
+		"@" + returnAddressLabel,
+		"D=A",
+		"@R14",
+		"M=D",
+		fmt.Sprintf("@%d", n),
+		"D=A",
+		"@R15",
+		"M=D",
+		"@" + funcName,
+		"D=A",
+	}, "\n"))
+	w.writeJmp(sharedCallLabel)
+	w.writeLine(fmt.Sprintf("(%s)", returnAddressLabel))
+}
+
+// WriteSharedRoutines 写入共享的$$call和$$return，每个程序只需要一份。
+// 前面是一个死循环，执行完前面的代码时停在这里而不是进入$$call
+func (w *CodeWriter) WriteSharedRoutines() {
+	if !w.sharedCalls {
+		return
+	}
+	w.writeLine(fmt.Sprintf("(%s)", sharedEndLabel))
+	w.writeJmp(sharedEndLabel)
+
+	lines := []string{
+		"(" + sharedCallLabel + ")",
+		// R13 = 被调函数
+		"@R13",
+		"M=D",
+		// push 返回地址, LCL, ARG, THIS, THAT
+		"@R14",
+		"D=M",
+		"@SP",
+		"A=M",
+		"M=D",
+	}
+	for _, reg := range []string{"LCL", "ARG", "THIS", "THAT"} {
+		lines = append(lines, "@"+reg, "D=M", "@SP", "AM=M+1", "M=D")
+	}
+	lines = append(lines,
+		// LCL = SP
+		"@SP",
+		"MD=M+1",
+		"@LCL",
+		"M=D",
+		// ARG = SP - 5 - n
+		"@5",
+		"D=D-A",
+		"@R15",
+		"D=D-M",
+		"@ARG",
+		"M=D",
+		"@R13",
+		"A=M",
+		"0;JMP",
+
+		"("+sharedReturnLabel+")",
+		// R14 = RET = *(LCL-5)，没有参数时*ARG就是RET，所以先读出来
+		"@LCL",
+		"D=M",
+		"@5",
+		"A=D-A",
+		"D=M",
+		"@R14",
+		"M=D",
+		// *ARG = pop()
+		"@SP",
+		"AM=M-1",
+		"D=M",
+		"@ARG",
+		"A=M",
+		"M=D",
+		// SP = ARG + 1
+		"@ARG",
+		"D=M+1",
+		"@SP",
+		"M=D",
+	)
+	// 从LCL往下依次恢复THAT, THIS, ARG, LCL
+	for _, reg := range []string{"THAT", "THIS", "ARG", "LCL"} {
+		lines = append(lines, "@LCL", "AM=M-1", "D=M", "@"+reg, "M=D")
+	}
+	lines = append(lines, "@R14", "A=M", "0;JMP")
+	w.writeLine(strings.Join(lines, "\n"))
+}
+
 func (w *CodeWriter) WriteFunction(funcName string, k int64) {
 	w.writeLine(fmt.Sprintf("(%s)", funcName))
 	for i := int64(0); i < k; i++ {
@@ -230,33 +415,53 @@ func (w *CodeWriter) writeEq() {
 }
 
 func (w *CodeWriter) writeGt() {
-	v := w.getJumpFlagCount()
-	w.writeLine(popM())
-	w.writeLine("D=-M")
-	w.writeLine(popM())
-	w.writeLine(strings.Join([]string{
-		"D=D+M",
-		"@writeTrue." + v,
-		"D;JGT",
-		"D=0",
-		"@writeFalse." + v,
-		"0;JMP",
-		"(" + "writeTrue." + v + ")",
-		"D=-1",
-		"(" + "writeFalse." + v + ")"},
-		"\n"))
-	w.writeLine(pushD())
+	w.writeCompare("JGT", "JGE")
 }
 
 func (w *CodeWriter) writeLt() {
+	w.writeCompare("JLT", "JLT")
+}
+
+// writeCompare 比较x和y，y在栈顶。x-y在x和y符号不同时会溢出，
+// 这时结果只取决于x的符号，用signJump判断x
+func (w *CodeWriter) writeCompare(jump, signJump string) {
 	v := w.getJumpFlagCount()
-	w.writeLine(popM())
-	w.writeLine("D=-M")
+	w.writeLine(popD())
+	w.writeLine("@13")
+	w.writeLine("M=D")
 	w.writeLine(popM())
 	w.writeLine(strings.Join([]string{
-		"D=D+M",
-		"@writeTrue." + v,
+		"D=M",
+		"@xNegative." + v,
+		"D;JLT",
+		"@13",
+		"D=M",
+		"@sameSign." + v,
+		"D;JGE",
+		"@signDiffer." + v,
+		"0;JMP",
+		"(" + "xNegative." + v + ")",
+		"@13",
+		"D=M",
+		"@sameSign." + v,
 		"D;JLT",
+		"(" + "signDiffer." + v + ")",
+		"@SP",
+		"A=M",
+		"D=M",
+		"@writeTrue." + v,
+		"D;" + signJump,
+		"@setFalse." + v,
+		"0;JMP",
+		"(" + "sameSign." + v + ")",
+		"@13",
+		"D=M",
+		"@SP",
+		"A=M",
+		"D=M-D",
+		"@writeTrue." + v,
+		"D;" + jump,
+		"(" + "setFalse." + v + ")",
 		"D=0",
 		"@writeFalse." + v,
 		"0;JMP",
@@ -290,6 +495,11 @@ func (w *CodeWriter) writeNot() {
 }
 
 func (w *CodeWriter) writeLine(line string) {
+	for _, l := range strings.Split(line, "\n") {
+		if l != "" && !strings.HasPrefix(l, "(") {
+			w.instructions += 1
+		}
+	}
 	w.bufWriter.WriteString(line)
 	w.bufWriter.WriteString("\n")
 }
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"nand2tetris/06/assembler/emulator"
+	"nand2tetris/06/assembler/hackasm"
+)
+
+// vmHeap this和that通常指向的区域，直到屏幕为止
+const (
+	vmHeap       = 2048
+	vmHeapEnd    = 16384
+	vmFrameSize  = 5
+	vmFirstLocal = vmStackBase + vmFrameSize
+)
+
+// Mismatch VM和翻译后的程序中值不同的RAM单元
+type Mismatch struct {
+	Name string
+	VM   uint16
+	Hack uint16
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: vm %d, hack %d", m.Name, int16(m.VM), int16(m.Hack))
+}
+
+// DiffResult 两次运行在比较时的状态
+type DiffResult struct {
+	// Steps 执行的VM命令数，Cycles 执行的Hack指令数
+	Steps    int64
+	Cycles   int64
+	Halted   bool
+	Function string
+	// Command 比较时所在的标签或函数的位置file:line，
+	// 或者程序结尾
+	Command    string
+	Mismatches []Mismatch
+}
+
+// Differ 在VM中运行VM程序，同时用某个后端和汇编器翻译后在Hack模拟器中运行，
+// 然后在同一位置比较两者的内存。
+// 后端在基本块内可能把值放在寄存器中，所以比较的位置
+// 是基本块的开头：标签、函数入口以及程序结尾
+type Differ struct {
+	vm       *VM
+	computer *emulator.Computer
+	symbols  *hackasm.SymbolTable
+	// end 程序执行完最后一条命令后所在的地址
+	end int
+}
+
+// DiffOptions 程序的翻译方式以及OS所在的位置
+type DiffOptions struct {
+	// Backend 后端的名字，与-backend相同
+	Backend     string
+	SharedCalls bool
+	// OSDir 未定义的函数从这里的.vm文件加载
+	OSDir string
+}
+
+// NewDiffer 加载并翻译.vm文件
+func NewDiffer(options DiffOptions, paths ...string) (*Differ, error) {
+	newBackend, ok := backends[options.Backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend '%s'", options.Backend)
+	}
+	d := &Differ{vm: NewVM()}
+	d.vm.OSDir = options.OSDir
+	if err := d.vm.Load(paths...); err != nil {
+		return nil, err
+	}
+
+	// 与main.go的布局相同：共享代码在引导代码之后，
+	// 没有引导代码时在程序之后
+	var asm bytes.Buffer
+	w := newBackend(&asm, "")
+	w.SetSharedCalls(options.SharedCalls)
+	if d.hasSysInit() {
+		w.WriteInit()
+		w.WriteSharedRoutines()
+	}
+	class := ""
+	for _, cmd := range d.vm.commands {
+		if cmd.class != class {
+			// 与main.go相同，静态变量以类的.tmp文件命名
+			class = cmd.class
+			w.setClass(class)
+		}
+		w.WriteCommand(cmd.Command)
+	}
+	if !d.hasSysInit() {
+		w.WriteSharedRoutines()
+	}
+	w.Close()
+
+	words, symbols, _, err := hackasm.AssembleFile("", &asm, hackasm.Options{})
+	if err != nil {
+		return nil, err
+	}
+	computer, err := emulator.NewComputer(words)
+	if err != nil {
+		return nil, err
+	}
+	d.computer = computer
+	d.symbols = symbols
+	d.end = len(words)
+	if symbols.Contains(sharedEndLabel) && !d.hasSysInit() {
+		d.end = symbols.GetAddress(sharedEndLabel)
+	}
+	return d, nil
+}
+
+func (d *Differ) hasSysInit() bool {
+	_, ok := d.vm.functions["Sys.init"]
+	return ok
+}
+
+// syncAddress 两个程序在pc处的命令上状态相同时返回它的ROM地址，
+// 即标签、函数入口或者程序结尾
+func (d *Differ) syncAddress(pc int) (uint16, bool) {
+	if pc >= len(d.vm.commands) {
+		return uint16(d.end), true
+	}
+	cmd := d.vm.commands[pc]
+	symbol := ""
+	switch cmd.commandType {
+	case C_FUNCTION:
+		symbol = cmd.Arg1
+	case C_LABEL:
+		symbol = cmd.function + "." + cmd.Arg1
+	default:
+		return 0, false
+	}
+	if !d.symbols.Contains(symbol) {
+		return 0, false
+	}
+	return uint16(d.symbols.GetAddress(symbol)), true
+}
+
+// maxInstructionsPerStep 一条VM命令最多执行的Hack指令数，
+// 用于停止跑飞的翻译结果
+const maxInstructionsPerStep = 200
+
+// Run 至少执行maxSteps条（<=0表示不限制）VM命令，直到下一个标签或函数，
+// 或者直到VM停止，然后将Hack程序运行到同一位置
+// 并比较内存
+func (d *Differ) Run(maxSteps int64) (*DiffResult, error) {
+	vm := d.vm
+	// SP=256并调用Sys.init，或者像07的测试脚本一样
+	// 设置好各内存段后从第一条命令开始
+	if d.hasSysInit() {
+		vm.Bootstrap()
+	} else {
+		vm.Reset()
+		for i, value := range []uint16{vmStackBase, 300, 400, 3000, 3010} {
+			vm.RAM[i] = value
+			d.computer.RAM[i] = value
+		}
+	}
+
+	// Hack程序经过同步点的地址的次数与VM到达该点的次数相同时，
+	// 两者才处于相同的状态
+	arrivals := map[uint16]int64{}
+	address, synced := d.syncAddress(vm.PC)
+	if synced {
+		arrivals[address] += 1
+	}
+	for !vm.Halted() && (maxSteps <= 0 || vm.Steps < maxSteps || !synced) {
+		pc := vm.PC
+		for pc < len(vm.commands) && vm.commands[pc].commandType == C_LABEL {
+			pc += 1
+		}
+		if err := vm.Step(); err != nil {
+			return nil, err
+		}
+		address, synced = d.syncAddress(vm.PC)
+		// 没有局部变量的function没有代码，与其后的标签地址相同
+		if pc < len(vm.commands) && vm.commands[pc].commandType == C_FUNCTION && vm.commands[pc].Arg2 == 0 {
+			continue
+		}
+		if synced {
+			arrivals[address] += 1
+		}
+	}
+	if !synced {
+		return nil, fmt.Errorf("VM halted at command %d, not a label or function", vm.PC)
+	}
+	if err := d.runHack(address, arrivals[address]); err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{
+		Steps:    vm.Steps,
+		Cycles:   d.computer.Cycles,
+		Halted:   vm.Halted(),
+		Function: vm.CurrentFunction(),
+	}
+	result.Command = "the end"
+	if vm.PC < len(vm.commands) {
+		cmd := vm.commands[vm.PC]
+		result.Command = fmt.Sprintf("%s:%d", cmd.file, cmd.line)
+	}
+	result.Mismatches = d.compare()
+	return result, nil
+}
+
+// runHack 运行Hack程序直到第n次到达address
+func (d *Differ) runHack(address uint16, n int64) error {
+	computer := d.computer
+	limit := (d.vm.Steps + 10) * maxInstructionsPerStep
+	count := int64(0)
+	if computer.PC == address {
+		count += 1
+	}
+	for count < n {
+		if computer.Cycles > limit {
+			return fmt.Errorf("translated program did not reach ROM[%d] %d times in %d instructions", address, n, limit)
+		}
+		if err := computer.Step(); err != nil {
+			return err
+		}
+		if computer.PC == address {
+			count += 1
+		}
+	}
+	return nil
+}
+
+// compare 比较指针、temp、静态变量、SP以下的栈以及堆。
+// R13-R15是翻译后代码的临时寄存器，栈帧中的返回地址
+// 一边是命令下标一边是ROM地址，所以都不比较
+func (d *Differ) compare() []Mismatch {
+	vmRAM, hackRAM := &d.vm.RAM, &d.computer.RAM
+	var mismatches []Mismatch
+	check := func(name string, vmAddress, hackAddress int) {
+		if vmRAM[vmAddress] != hackRAM[hackAddress] {
+			mismatches = append(mismatches, Mismatch{Name: name, VM: vmRAM[vmAddress], Hack: hackRAM[hackAddress]})
+		}
+	}
+
+	for i, name := range []string{"SP", "LCL", "ARG", "THIS", "THAT"} {
+		check(name, i, i)
+	}
+	for i := 0; i < 8; i++ {
+		check(fmt.Sprintf("temp %d", i), vmTemp+i, vmTemp+i)
+	}
+
+	keys := make([]string, 0, len(d.vm.statics))
+	for key := range d.vm.statics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		address := d.vm.statics[key]
+		dot := strings.LastIndex(key, ".")
+		symbol := key[:dot] + ".tmp" + key[dot:]
+		if !d.symbols.Contains(symbol) {
+			continue
+		}
+		check("static "+key, int(address), d.symbols.GetAddress(symbol))
+	}
+
+	sp := int(vmRAM[SP])
+	returns := map[int]bool{}
+	for lcl := int(vmRAM[LCL]); lcl >= vmFirstLocal && lcl <= sp; {
+		returns[lcl-vmFrameSize] = true
+		saved := int(vmRAM[lcl-4])
+		if saved >= lcl {
+			break
+		}
+		lcl = saved
+	}
+	for i := vmStackBase; i < sp && i < vmHeap; i++ {
+		if !returns[i] {
+			check(fmt.Sprintf("stack RAM[%d]", i), i, i)
+		}
+	}
+	for i := vmHeap; i < vmHeapEnd; i++ {
+		check(fmt.Sprintf("RAM[%d]", i), i, i)
+	}
+	return mismatches
+}
+
+// Write 输出比较的位置以及每个不同的单元
+func (r *DiffResult) Write(writer io.Writer) {
+	state := "step limit reached"
+	if r.Halted {
+		state = "halted"
+	}
+	fmt.Fprintf(writer, "// compared at %s, %s, steps: %d, instructions: %d, function: %s\n",
+		r.Command, state, r.Steps, r.Cycles, r.Function)
+	for _, m := range r.Mismatches {
+		fmt.Fprintln(writer, m)
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// genFunction 生成的程序中的一个函数。局部变量先是任何语句都可以写的数据，
+// 然后是每层嵌套循环各一个计数器
+type genFunction struct {
+	name   string
+	class  string
+	nArgs  int
+	nData  int
+	nLoops int
+}
+
+// vmGenerator 生成栈保持平衡而且总会停止的随机VM程序：
+// 循环对只有它自己写的局部变量倒数，函数只调用在它之后定义的函数，
+// 所以没有递归
+type vmGenerator struct {
+	rng       *rand.Rand
+	functions []genFunction
+	current   int
+	out       *strings.Builder
+	labels    int
+	calls     int
+}
+
+const (
+	genMaxLoops  = 2
+	genMaxCalls  = 3
+	genMaxDepth  = 3
+	genStatics   = 4
+	genHeapRange = 2000
+)
+
+// generateProgram 按文件名返回一个随机程序的各个.vm文件
+func generateProgram(rng *rand.Rand) map[string]string {
+	g := &vmGenerator{rng: rng}
+	classes := []string{"Sys"}
+	for i := rng.Intn(3); i >= 0; i-- {
+		classes = append(classes, fmt.Sprintf("Class%d", len(classes)))
+	}
+	g.functions = append(g.functions, genFunction{name: "Sys.init", class: "Sys", nData: 2, nLoops: genMaxLoops})
+	for i := rng.Intn(5) + 1; i > 0; i-- {
+		class := classes[rng.Intn(len(classes))]
+		g.functions = append(g.functions, genFunction{
+			name:   fmt.Sprintf("%s.f%d", class, len(g.functions)),
+			class:  class,
+			nArgs:  rng.Intn(4),
+			nData:  rng.Intn(3),
+			nLoops: genMaxLoops,
+		})
+	}
+
+	sources := map[string]*strings.Builder{}
+	for i, f := range g.functions {
+		if sources[f.class] == nil {
+			sources[f.class] = &strings.Builder{}
+		}
+		g.current = i
+		g.out = sources[f.class]
+		g.calls = 0
+		g.function()
+	}
+	files := map[string]string{}
+	for class, source := range sources {
+		files[class+".vm"] = source.String()
+	}
+	return files
+}
+
+func (g *vmGenerator) emit(format string, args ...interface{}) {
+	fmt.Fprintf(g.out, format+"\n", args...)
+}
+
+func (g *vmGenerator) label() string {
+	g.labels += 1
+	return fmt.Sprintf("L%d", g.labels)
+}
+
+func (g *vmGenerator) function() {
+	f := g.functions[g.current]
+	g.emit("function %s %d", f.name, f.nData+f.nLoops)
+	g.setPointer(0)
+	g.setPointer(1)
+	g.statements(g.rng.Intn(6)+1, 0)
+	if f.name == "Sys.init" {
+		end := g.label()
+		g.emit("label %s", end)
+		g.emit("goto %s", end)
+		return
+	}
+	g.expression(0)
+	g.emit("return")
+}
+
+// setPointer 让this或that指向堆
+func (g *vmGenerator) setPointer(i int) {
+	g.emit("push constant %d", vmHeap+g.rng.Intn(genHeapRange))
+	g.emit("pop pointer %d", i)
+}
+
+func (g *vmGenerator) statements(n, loops int) {
+	for i := 0; i < n; i++ {
+		g.statement(loops)
+	}
+}
+
+func (g *vmGenerator) statement(loops int) {
+	switch r := g.rng.Intn(10); {
+	case r < 5:
+		g.expression(0)
+		g.emit("pop %s", g.destination())
+	case r < 6:
+		g.setPointer(g.rng.Intn(2))
+	case r < 8:
+		// if-goto跳到第二个分支，第一个分支执行完跳过第二个
+		g.expression(0)
+		then, end := g.label(), g.label()
+		g.emit("if-goto %s", then)
+		g.statements(g.rng.Intn(3), loops)
+		g.emit("goto %s", end)
+		g.emit("label %s", then)
+		g.statements(g.rng.Intn(3), loops)
+		g.emit("label %s", end)
+	default:
+		if loops >= genMaxLoops {
+			g.expression(0)
+			g.emit("pop temp %d", g.rng.Intn(8))
+			return
+		}
+		counter := g.functions[g.current].nData + loops
+		loop, end := g.label(), g.label()
+		g.emit("push constant %d", g.rng.Intn(4))
+		g.emit("pop local %d", counter)
+		g.emit("label %s", loop)
+		g.emit("push local %d", counter)
+		g.emit("push constant 0")
+		if g.rng.Intn(2) == 0 {
+			g.emit("eq")
+		} else {
+			// 与Jack编译器的写法相同的while (counter > 0)
+			g.emit("gt")
+			g.emit("not")
+		}
+		g.emit("if-goto %s", end)
+		g.statements(g.rng.Intn(3)+1, loops+1)
+		g.emit("push local %d", counter)
+		g.emit("push constant 1")
+		g.emit("sub")
+		g.emit("pop local %d", counter)
+		g.emit("goto %s", loop)
+		g.emit("label %s", end)
+	}
+}
+
+// destination 语句可以pop到的内存段，不会是循环计数器
+func (g *vmGenerator) destination() string {
+	f := g.functions[g.current]
+	for {
+		switch g.rng.Intn(6) {
+		case 0:
+			if f.nData > 0 {
+				return fmt.Sprintf("local %d", g.rng.Intn(f.nData))
+			}
+		case 1:
+			if f.nArgs > 0 {
+				return fmt.Sprintf("argument %d", g.rng.Intn(f.nArgs))
+			}
+		case 2:
+			return fmt.Sprintf("static %d", g.rng.Intn(genStatics))
+		case 3:
+			return fmt.Sprintf("temp %d", g.rng.Intn(8))
+		case 4:
+			return fmt.Sprintf("this %d", g.rng.Intn(8))
+		case 5:
+			return fmt.Sprintf("that %d", g.rng.Intn(8))
+		}
+	}
+}
+
+var genConstants = []int{0, 1, 2, 16384, 32767}
+
+// expression 正好push一个值
+func (g *vmGenerator) expression(depth int) {
+	f := g.functions[g.current]
+	r := g.rng.Intn(10)
+	if depth >= genMaxDepth {
+		r = 0
+	}
+	switch {
+	case r < 2:
+		if g.rng.Intn(2) == 0 {
+			g.emit("push constant %d", genConstants[g.rng.Intn(len(genConstants))])
+		} else {
+			g.emit("push constant %d", g.rng.Intn(32768))
+		}
+	case r < 4:
+		switch g.rng.Intn(5) {
+		case 0:
+			if f.nArgs > 0 {
+				g.emit("push argument %d", g.rng.Intn(f.nArgs))
+				return
+			}
+			g.emit("push local %d", g.rng.Intn(f.nData+f.nLoops))
+		case 1:
+			g.emit("push local %d", g.rng.Intn(f.nData+f.nLoops))
+		case 2:
+			g.emit("push static %d", g.rng.Intn(genStatics))
+		case 3:
+			g.emit("push temp %d", g.rng.Intn(8))
+		case 4:
+			g.emit("push %s %d", []string{"this", "that", "pointer"}[g.rng.Intn(3)], g.rng.Intn(2))
+		}
+	case r < 5:
+		g.expression(depth + 1)
+		g.emit([]string{"neg", "not"}[g.rng.Intn(2)])
+	case r < 9:
+		g.expression(depth + 1)
+		g.expression(depth + 1)
+		g.emit([]string{"add", "sub", "and", "or", "eq", "gt", "lt"}[g.rng.Intn(7)])
+	default:
+		if g.current+1 >= len(g.functions) || g.calls >= genMaxCalls {
+			g.emit("push constant %d", g.rng.Intn(32768))
+			return
+		}
+		g.calls += 1
+		callee := g.functions[g.current+1+g.rng.Intn(len(g.functions)-g.current-1)]
+		for i := 0; i < callee.nArgs; i++ {
+			g.expression(depth + 1)
+		}
+		g.emit("call %s %d", callee.name, callee.nArgs)
+	}
+}
+
+func writeProgram(t testing.TB, files map[string]string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// checkDiff 大约运行maxSteps步，有任何不同时报错，
+// 同时输出程序
+func checkDiff(t testing.TB, options DiffOptions, files map[string]string, maxSteps int64) *DiffResult {
+	t.Helper()
+	differ, err := NewDiffer(options, writeProgram(t, files)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := differ.Run(maxSteps)
+	if err == nil && len(result.Mismatches) == 0 {
+		return result
+	}
+	var program strings.Builder
+	for name, source := range files {
+		fmt.Fprintf(&program, "// %s\n%s", name, source)
+	}
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", options, err, program.String())
+	}
+	var report strings.Builder
+	result.Write(&report)
+	t.Fatalf("%s: %s%s", options, report.String(), program.String())
+	return nil
+}
+
+// allDiffOptions 每个后端，分别使用和不使用共享的call
+func allDiffOptions() []DiffOptions {
+	var all []DiffOptions
+	for backend := range backends {
+		for _, shared := range []bool{false, true} {
+			all = append(all, DiffOptions{Backend: backend, SharedCalls: shared})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].String() < all[j].String() })
+	return all
+}
+
+func (o DiffOptions) String() string {
+	if o.SharedCalls {
+		return o.Backend + "-shared"
+	}
+	return o.Backend
+}
+
+func TestDiffCompare(t *testing.T) {
+	// 这些例子的x-y都会溢出，结果由x和y的符号决定
+	var source strings.Builder
+	source.WriteString("function Sys.init 0\n")
+	pairs := [][2]string{
+		{"push constant 32767", "push constant 1\nneg"},
+		{"push constant 1\nneg", "push constant 32767"},
+		{"push constant 32767\nneg\npush constant 1\nsub", "push constant 1"},
+		{"push constant 1", "push constant 32767\nneg\npush constant 1\nsub"},
+		{"push constant 16384", "push constant 16384\nneg"},
+	}
+	for _, pair := range pairs {
+		for _, op := range []string{"gt", "lt", "eq"} {
+			fmt.Fprintf(&source, "%s\n%s\n%s\n", pair[0], pair[1], op)
+		}
+	}
+	source.WriteString("label END\ngoto END\n")
+	for _, options := range allDiffOptions() {
+		result := checkDiff(t, options, map[string]string{"Sys.vm": source.String()}, 0)
+		if !result.Halted {
+			t.Fatalf("%s: not halted after %d steps", options, result.Steps)
+		}
+	}
+}
+
+func TestDiffCoursePrograms(t *testing.T) {
+	dirs := []string{
+		"../StackArithmetic/SimpleAdd",
+		"../StackArithmetic/StackTest",
+		"../MemoryAccess/BasicTest",
+		"../MemoryAccess/PointerTest",
+		"../MemoryAccess/StaticTest",
+		"../../08/FunctionCalls/FibonacciElement",
+		"../../08/FunctionCalls/NestedCall",
+		"../../08/FunctionCalls/StaticsTest",
+	}
+	for _, options := range allDiffOptions() {
+		for _, dir := range dirs {
+			t.Run(options.String()+"/"+filepath.Base(dir), func(t *testing.T) {
+				files, err := vmFiles(dir)
+				if err != nil {
+					t.Skip(err)
+				}
+				differ, err := NewDiffer(options, files...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result, err := differ.Run(100000)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !result.Halted || len(result.Mismatches) > 0 {
+					var report strings.Builder
+					result.Write(&report)
+					t.Fatal(report.String())
+				}
+			})
+		}
+	}
+}
+
+// TestDiffRandom 在随机程序停止时以及执行随机步数之后比较，
+// 后者可能停在任何地方，比如call的中间
+func TestDiffRandom(t *testing.T) {
+	n := 200
+	if testing.Short() {
+		n = 30
+	}
+	for seed := int64(0); seed < int64(n); seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		files := generateProgram(rng)
+		steps := int64(rng.Intn(500) + 1)
+		for _, options := range allDiffOptions() {
+			checkDiff(t, options, files, 20000)
+			checkDiff(t, options, files, steps)
+		}
+	}
+}
+
+func FuzzDiff(f *testing.F) {
+	for seed := int64(0); seed < 8; seed++ {
+		f.Add(seed, int64(0))
+	}
+	f.Fuzz(func(t *testing.T, seed int64, steps int64) {
+		files := generateProgram(rand.New(rand.NewSource(seed)))
+		if steps <= 0 || steps > 20000 {
+			steps = 20000
+		}
+		for _, options := range allDiffOptions() {
+			checkDiff(t, options, files, steps)
+		}
+	})
+}
@@ -1,3 +1,7 @@
 module nand2tetris/07/translator
 
-go 1.17
+go 1.18
+
+require nand2tetris/06/assembler v0.0.0
+
+replace nand2tetris/06/assembler => ../../06/assembler
@@ -2,18 +2,59 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"nand2tetris/06/assembler/hackasm"
 )
 
 var flagPath = flag.String("input", "", "")
+var runVM = flag.Bool("run", false, "run the .vm file or directory in the VM emulator instead of translating it")
+var script = flag.String("tst", "", "run a VM emulator .tst script and compare the output with its .cmp file")
+var osDir = flag.String("os", "", "directory of the Jack OS .vm files used for undefined functions, tools/OS above the input by default")
+var diffVM = flag.Bool("diff", false, "run the .vm file or directory in the VM emulator and translated by -backend in the Hack emulator, and compare the memory of both")
+var maxSteps = flag.Int64("steps", 10000000, "with -run or -diff, max VM commands to execute, 0 means no limit")
+var backendName = flag.String("backend", "reference", "code generator: reference, one template per command, or optimized, which keeps the top of the stack in D and fuses commands")
+var sharedCalls = flag.Bool("shared-calls", false, "jump to one shared copy of the call and return code instead of writing it at every call and return")
+var stats = flag.Bool("stats", false, "print the number of Hack instructions generated for each file")
+var dumpRanges = flag.String("dump", "0-15", "with -run, comma separated RAM ranges to dump, e.g. 0-15,256-300")
 
 func main() {
 	flag.Parse()
 
+	if *script != "" {
+		runner := NewScriptRunner()
+		runner.OSDir = findOSDir(*script)
+		if err := runner.RunFile(*script); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("End of script - Comparison ended successfully")
+		return
+	}
+
 	path := *flagPath
+	if *runVM {
+		if err := run(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *diffVM {
+		same, err := diff(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !same {
+			os.Exit(1)
+		}
+		return
+	}
 
 	fileStat, err := os.Stat(path)
 	if err != nil {
@@ -37,12 +78,23 @@ func main() {
 
 	allOutputPaths := make([]string, 0, len(allVMFile))
 
-	var codeWriter *CodeWriter
+	if _, ok := backends[*backendName]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown backend '%s', expect reference or optimized\n", *backendName)
+		os.Exit(1)
+	}
+
+	var codeWriter Backend
+	var errs hackasm.ErrorList
+	counts := make([]int, 0, len(allVMFile))
 	for i, filePath := range allVMFile {
 		outputPath := filePath[:len(filePath)-len("vm")] + "tmp"
 		allOutputPaths = append(allOutputPaths, outputPath)
 		if i == 0 {
-			codeWriter = NewCodeWriter(outputPath)
+			codeWriter, err = NewBackend(*backendName, outputPath)
+			if err != nil {
+				panic(err)
+			}
+			codeWriter.SetSharedCalls(*sharedCalls)
 		} else {
 			codeWriter.SetFileName(outputPath)
 		}
@@ -50,38 +102,46 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		parser := NewParser(file)
+		before := codeWriter.Instructions()
+		parser := NewParser(filePath, file)
 		for parser.HasMoreCommands() {
-			parser.Advance()
-			switch parser.CommandType() {
-			case C_ARITHMETIC:
-				codeWriter.WriteArithmetic(parser.Arg1())
-			case C_PUSH, C_POP:
-				codeWriter.WritePushPop(parser.CommandType(), parser.Arg1(), parser.Arg2())
-			case C_LABEL:
-				codeWriter.WriteLabel(parser.Arg1())
-			case C_GOTO:
-				codeWriter.WriteGoto(parser.Arg1())
-			case C_IF:
-				codeWriter.WriteIf(parser.Arg1())
-			case C_FUNCTION:
-				codeWriter.WriteFunction(parser.Arg1(), parser.Arg2())
-			case C_RETURN:
-				codeWriter.WriteReturn()
-			case C_CALL:
-				codeWriter.WriteCall(parser.Arg1(), int32(parser.Arg2()))
+			if err := parser.Advance(); err != nil {
+				errs = append(errs, err)
+				continue
 			}
+			codeWriter.WriteCommand(parser.curCommand)
 		}
+		file.Close()
+		// Close写出writer中剩余的代码，之后还可以继续写下一个文件
+		codeWriter.Close()
+		counts = append(counts, codeWriter.Instructions()-before)
+	}
+	if err := errs.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	if codeWriter != nil {
+
+	// 目录的共享代码放在引导代码之后，单个文件的放在结尾
+	routines := 0
+	if !fileStat.IsDir() && codeWriter != nil {
+		before := codeWriter.Instructions()
+		codeWriter.WriteSharedRoutines()
 		codeWriter.Close()
+		routines = codeWriter.Instructions() - before
 	}
 
+	bootstrap := 0
 	if fileStat.IsDir() {
 		dirName := filepath.Base(path)
 		totalOutputPath := filepath.Join(path, dirName+".asm")
-		totalOutputWriter := NewCodeWriter(totalOutputPath)
+		totalOutputWriter, err := NewBackend(*backendName, totalOutputPath)
+		if err != nil {
+			panic(err)
+		}
+		totalOutputWriter.SetSharedCalls(*sharedCalls)
 		totalOutputWriter.WriteInit()
+		totalOutputWriter.WriteSharedRoutines()
+		bootstrap = totalOutputWriter.Instructions()
 
 		for _, outputPath := range allOutputPaths {
 			totalOutputWriter.WriteRawFile(outputPath)
@@ -89,4 +149,18 @@ func main() {
 		totalOutputWriter.Close()
 	}
 
+	if *stats {
+		total := bootstrap + routines
+		for i, count := range counts {
+			fmt.Printf("%8d %s\n", count, allVMFile[i])
+			total += count
+		}
+		if fileStat.IsDir() {
+			fmt.Printf("%8d bootstrap\n", bootstrap)
+		}
+		if routines > 0 {
+			fmt.Printf("%8d shared call and return\n", routines)
+		}
+		fmt.Printf("%8d total\n", total)
+	}
 }
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// OptimizingWriter 生成比CodeWriter更小更快的代码。在基本块内栈顶放在D中
+// 而不是RAM中，push之后马上使用的值不会写入内存，
+// 常见的命令序列会合并：push constant n; add变为D=D+A，
+// 比较或not之后的if-goto变为条件跳转。
+// 在标签、函数入口以及goto、if-goto、call和return前后，
+// 栈与CodeWriter一样全部在RAM中
+type OptimizingWriter struct {
+	*CodeWriter
+	// block 当前基本块的命令，直到基本块结束
+	block []Command
+	// cached 栈顶在D中，并且SP没有把它计算在内
+	cached bool
+}
+
+func NewOptimizingWriter(path string) *OptimizingWriter {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
+	if err != nil {
+		panic(err)
+	}
+	return newOptimizingWriter(file, path)
+}
+
+func newOptimizingWriter(writer io.Writer, path string) *OptimizingWriter {
+	return &OptimizingWriter{CodeWriter: newCodeWriter(writer, path)}
+}
+
+func (w *OptimizingWriter) SetFileName(filename string) {
+	w.flushBlock()
+	w.CodeWriter.SetFileName(filename)
+}
+
+func (w *OptimizingWriter) setClass(class string) {
+	w.flushBlock()
+	w.CodeWriter.setClass(class)
+}
+
+func (w *OptimizingWriter) WriteSharedRoutines() {
+	w.flushBlock()
+	w.CodeWriter.WriteSharedRoutines()
+}
+
+func (w *OptimizingWriter) Close() {
+	w.flushBlock()
+	w.CodeWriter.Close()
+}
+
+func (w *OptimizingWriter) WriteCommand(cmd Command) {
+	switch cmd.commandType {
+	case C_COMMENT:
+	case C_LABEL:
+		w.flushBlock()
+		w.WriteLabel(cmd.Arg1)
+	case C_FUNCTION:
+		w.flushBlock()
+		w.writeFunction(cmd.Arg1, cmd.Arg2)
+	case C_GOTO, C_IF, C_CALL, C_RETURN:
+		w.block = append(w.block, cmd)
+		w.flushBlock()
+	default:
+		w.block = append(w.block, cmd)
+	}
+}
+
+// flushBlock 写入基本块的命令，之后栈全部在RAM中
+func (w *OptimizingWriter) flushBlock() {
+	for i := 0; i < len(w.block); {
+		i += w.writeFused(w.block[i:])
+	}
+	w.spill()
+	w.block = w.block[:0]
+}
+
+func (w *OptimizingWriter) lines(lines ...string) {
+	w.writeLine(strings.Join(lines, "\n"))
+}
+
+// spill 将D中的栈顶写入RAM
+func (w *OptimizingWriter) spill() {
+	if w.cached {
+		w.lines("@SP", "AM=M+1", "A=A-1", "M=D")
+		w.cached = false
+	}
+}
+
+// load 将栈顶pop到D中，已经在D中时什么也不做
+func (w *OptimizingWriter) load() {
+	if !w.cached {
+		w.lines("@SP", "AM=M-1", "D=M")
+		w.cached = true
+	}
+}
+
+// operand 被push的值可以由一条指令以A或M读出而不改变D时，
+// 可以直接使用而不必push
+type operand struct {
+	lines    []string
+	register string
+	constant bool
+	value    int64
+}
+
+var segmentRegisters = map[string]string{
+	"local":    "LCL",
+	"argument": "ARG",
+	"this":     "THIS",
+	"that":     "THAT",
+}
+
+// fixedAddress temp、pointer或static单元的符号
+func (w *OptimizingWriter) fixedAddress(segment string, index int64) (string, bool) {
+	switch segment {
+	case "temp":
+		return fmt.Sprintf("%d", 5+index), true
+	case "pointer":
+		return fmt.Sprintf("%d", 3+index), true
+	case "static":
+		return w.getStaticName(index), true
+	}
+	return "", false
+}
+
+func (w *OptimizingWriter) operand(cmd Command) (operand, bool) {
+	if cmd.commandType != C_PUSH {
+		return operand{}, false
+	}
+	if cmd.Arg1 == "constant" {
+		return operand{lines: []string{fmt.Sprintf("@%d", cmd.Arg2)}, register: "A", constant: true, value: cmd.Arg2}, true
+	}
+	if address, ok := w.fixedAddress(cmd.Arg1, cmd.Arg2); ok {
+		return operand{lines: []string{"@" + address}, register: "M"}, true
+	}
+	if reg, ok := segmentRegisters[cmd.Arg1]; ok && cmd.Arg2 <= 1 {
+		a := "A=M"
+		if cmd.Arg2 == 1 {
+			a = "A=M+1"
+		}
+		return operand{lines: []string{"@" + reg, a}, register: "M"}, true
+	}
+	return operand{}, false
+}
+
+var binaryOperators = map[string]string{
+	"add": "D+%s",
+	"sub": "D-%s",
+	"and": "D&%s",
+	"or":  "D|%s",
+}
+
+// writeFused 写入cmds的第一条命令，或者合并后的前几条命令，
+// 返回写入的命令数
+func (w *OptimizingWriter) writeFused(cmds []Command) int {
+	cmd := cmds[0]
+	switch cmd.commandType {
+	case C_PUSH:
+		if len(cmds) < 2 || cmds[1].commandType != C_ARITHMETIC {
+			break
+		}
+		y, ok := w.operand(cmd)
+		if !ok {
+			break
+		}
+		op := cmds[1].Arg1
+		if format, ok := binaryOperators[op]; ok {
+			w.load()
+			w.lines(y.lines...)
+			w.lines("D=" + fmt.Sprintf(format, y.register))
+			return 2
+		}
+		if op == "eq" || (op == "gt" || op == "lt") && y.constant {
+			w.load()
+			return 2 + w.writeCondition(op, &y, cmds[2:])
+		}
+	case C_ARITHMETIC:
+		switch op := cmd.Arg1; op {
+		case "eq", "gt", "lt":
+			w.load()
+			return 1 + w.writeCondition(op, nil, cmds[1:])
+		case "not":
+			if len(cmds) > 1 && cmds[1].commandType == C_IF {
+				// not是按位取反，只有x=-1时!x为0
+				w.load()
+				w.lines("D=D+1", "@"+w.getCurFuncLabel(cmds[1].Arg1), "D;JNE")
+				w.cached = false
+				return 2
+			}
+		}
+	}
+	w.writeCommand(cmd)
+	return 1
+}
+
+func (w *OptimizingWriter) writeCommand(cmd Command) {
+	switch cmd.commandType {
+	case C_ARITHMETIC:
+		w.writeArithmetic(cmd.Arg1)
+	case C_PUSH:
+		w.writePush(cmd.Arg1, cmd.Arg2)
+	case C_POP:
+		w.writePop(cmd.Arg1, cmd.Arg2)
+	case C_GOTO:
+		w.spill()
+		w.writeJmp(w.getCurFuncLabel(cmd.Arg1))
+	case C_IF:
+		w.load()
+		w.lines("@"+w.getCurFuncLabel(cmd.Arg1), "D;JNE")
+		w.cached = false
+	case C_CALL:
+		w.spill()
+		w.WriteCall(cmd.Arg1, int32(cmd.Arg2))
+	case C_RETURN:
+		w.writeReturn()
+	}
+}
+
+func (w *OptimizingWriter) writeArithmetic(op string) {
+	w.load()
+	if format, ok := binaryOperators[op]; ok {
+		// x在y之下，y在D中
+		w.lines("@SP", "AM=M-1")
+		if op == "sub" {
+			w.lines("D=M-D")
+		} else {
+			w.lines("D=" + fmt.Sprintf(format, "M"))
+		}
+		return
+	}
+	switch op {
+	case "neg":
+		w.lines("D=-D")
+	case "not":
+		w.lines("D=!D")
+	}
+}
+
+func (w *OptimizingWriter) writePush(segment string, index int64) {
+	w.spill()
+	if segment == "constant" {
+		switch index {
+		case 0, 1:
+			w.lines(fmt.Sprintf("D=%d", index))
+		default:
+			w.lines(fmt.Sprintf("@%d", index), "D=A")
+		}
+	} else if address, ok := w.fixedAddress(segment, index); ok {
+		w.lines("@"+address, "D=M")
+	} else {
+		w.lines(segmentAddress(segmentRegisters[segment], index)...)
+		w.lines("D=M")
+	}
+	w.cached = true
+}
+
+// segmentAddress 将A设为以reg为基址的内存段中index的地址，
+// 下标较大时使用D
+func segmentAddress(reg string, index int64) []string {
+	switch index {
+	case 0:
+		return []string{"@" + reg, "A=M"}
+	case 1:
+		return []string{"@" + reg, "A=M+1"}
+	}
+	return []string{"@" + reg, "D=M", fmt.Sprintf("@%d", index), "A=D+A"}
+}
+
+// maxIncrements pop通过递增A能到达的最大下标，
+// 更大的下标用D计算地址
+const maxIncrements = 6
+
+func (w *OptimizingWriter) writePop(segment string, index int64) {
+	if address, ok := w.fixedAddress(segment, index); ok {
+		w.load()
+		w.lines("@"+address, "M=D")
+		w.cached = false
+		return
+	}
+	reg := segmentRegisters[segment]
+	if index <= maxIncrements {
+		w.load()
+		w.lines("@"+reg, "A=M")
+		for i := int64(0); i < index; i++ {
+			w.lines("A=A+1")
+		}
+		w.lines("M=D")
+		w.cached = false
+		return
+	}
+	// D=address+value，然后A=D-value为地址，D-A为值
+	w.spill()
+	w.lines("@"+reg, "D=M", fmt.Sprintf("@%d", index), "D=D+A", "@SP", "AM=M-1", "D=D+M", "A=D-M", "M=D-A")
+}
+
+var negatedConditions = map[string]string{
+	"eq": "ne", "ne": "eq",
+	"gt": "le", "le": "gt",
+	"lt": "ge", "ge": "lt",
+}
+
+// writeCondition 写入x和y的比较op。y为nil时y在D中、x在栈上，
+// 否则x在D中。之后的if-goto，或者not和if-goto，
+// 直接跳转而不push布尔值。返回op之后写入的命令数
+func (w *OptimizingWriter) writeCondition(op string, y *operand, next []Command) int {
+	if len(next) > 0 && next[0].commandType == C_IF {
+		w.jumpIf(op, y, w.getCurFuncLabel(next[0].Arg1))
+		return 1
+	}
+	if len(next) > 1 && next[0].commandType == C_ARITHMETIC && next[0].Arg1 == "not" && next[1].commandType == C_IF {
+		w.jumpIf(negatedConditions[op], y, w.getCurFuncLabel(next[1].Arg1))
+		return 2
+	}
+	v := w.getJumpFlagCount()
+	w.jumpIf(op, y, "writeTrue."+v)
+	w.lines("D=0", "@writeFalse."+v, "0;JMP", "(writeTrue."+v+")", "D=-1", "(writeFalse."+v+")")
+	w.cached = true
+	return 0
+}
+
+// jumpIf x cond y成立时跳到target，之后栈全部在RAM中。
+// x和y符号不同时x-y会溢出，这时由符号决定结果
+func (w *OptimizingWriter) jumpIf(cond string, y *operand, target string) {
+	jump := "D;J" + strings.ToUpper(cond)
+	w.cached = false
+	if cond == "eq" || cond == "ne" {
+		if y != nil {
+			w.lines(y.lines...)
+			w.lines("D=D-" + y.register)
+		} else {
+			w.lines("@SP", "AM=M-1", "D=M-D")
+		}
+		w.lines("@"+target, jump)
+		return
+	}
+
+	v := w.getJumpFlagCount()
+	skip := "skip." + v
+	// x<0且y>=0（即x<y）时以及x>=0且y<0时的去向
+	whenLess, whenGreater := skip, skip
+	if cond == "lt" || cond == "le" {
+		whenLess = target
+	} else {
+		whenGreater = target
+	}
+
+	if y != nil {
+		// y是常量，不会是负数
+		if y.value != 0 {
+			w.lines("@"+whenLess, "D;JLT")
+			w.lines(y.lines...)
+			w.lines("D=D-A")
+		}
+		w.lines("@"+target, jump)
+		if y.value != 0 && whenLess == skip {
+			w.lines("(" + skip + ")")
+		}
+		return
+	}
+
+	w.lines(
+		"@R13",
+		"M=D",
+		"@SP",
+		"AM=M-1",
+		"D=M",
+		"@xNegative."+v,
+		"D;JLT",
+		"@R13",
+		"D=M",
+		"@"+whenGreater,
+		"D;JLT",
+		"@sameSign."+v,
+		"0;JMP",
+		"(xNegative."+v+")",
+		"@R13",
+		"D=M",
+		"@"+whenLess,
+		"D;JGE",
+		"(sameSign."+v+")",
+		"@R13",
+		"D=M",
+		"@SP",
+		"A=M",
+		"D=M-D",
+		"@"+target,
+		jump,
+		"("+skip+")",
+	)
+}
+
+// writeFunction 将局部变量置0，只更新一次SP
+func (w *OptimizingWriter) writeFunction(funcName string, k int64) {
+	w.writeLine(fmt.Sprintf("(%s)", funcName))
+	w.enterFunc(funcName)
+	switch {
+	case k == 1:
+		w.lines("@SP", "AM=M+1", "A=A-1", "M=0")
+	case k > 1:
+		w.lines("@SP", "A=M")
+		for i := int64(0); i < k; i++ {
+			w.lines("M=0", "A=A+1")
+		}
+		w.lines("D=A", "@SP", "M=D")
+	}
+}
+
+// writeReturn 从LCL往下依次恢复调用者的栈帧，而不是先复制一份
+func (w *OptimizingWriter) writeReturn() {
+	if w.sharedCalls {
+		w.spill()
+		w.WriteReturn()
+		return
+	}
+	// 先读出返回地址，没有参数时它就在*ARG处
+	if w.cached {
+		w.lines("@R13", "M=D")
+	}
+	w.lines("@LCL", "D=M", "@5", "A=D-A", "D=M", "@R14", "M=D")
+	if w.cached {
+		w.lines("@R13", "D=M")
+	} else {
+		w.lines("@SP", "A=M-1", "D=M")
+	}
+	w.cached = false
+	w.lines("@ARG", "A=M", "M=D")
+	w.lines("@ARG", "D=M+1", "@SP", "M=D")
+	for _, reg := range []string{"THAT", "THIS", "ARG", "LCL"} {
+		w.lines("@LCL", "AM=M-1", "D=M", "@"+reg, "M=D")
+	}
+	w.lines("@R14", "A=M", "0;JMP")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func translateWith(t *testing.T, backend string, source string) (string, int) {
+	t.Helper()
+	return translateShared(t, backend, false, source)
+}
+
+// translateShared 使用或不使用共享的call进行翻译，包括共享代码
+func translateShared(t *testing.T, backend string, shared bool, source string) (string, int) {
+	t.Helper()
+	var asm bytes.Buffer
+	w := backends[backend](&asm, "Main.tmp")
+	w.SetSharedCalls(shared)
+	parser := NewParser("Main.vm", strings.NewReader(source))
+	for parser.HasMoreCommands() {
+		if err := parser.Advance(); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteCommand(parser.curCommand)
+	}
+	w.WriteSharedRoutines()
+	w.Close()
+	return asm.String(), w.Instructions()
+}
+
+func TestOptimizingWriterFusion(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		expect string
+	}{
+		{
+			"push and pop through D",
+			"push local 2\npop static 1",
+			"@LCL\nD=M\n@2\nA=D+A\nD=M\n@Main.tmp.1\nM=D\n",
+		},
+		{
+			"add a constant",
+			"push argument 0\npush constant 7\nadd\npop temp 0",
+			"@ARG\nA=M\nD=M\n@7\nD=D+A\n@5\nM=D\n",
+		},
+		{
+			"compare with a constant and jump",
+			"function Main.f 0\npush local 0\npush constant 10\nlt\nif-goto END",
+			"(Main.f)\n@LCL\nA=M\nD=M\n@Main.f.END\nD;JLT\n@10\nD=D-A\n@Main.f.END\nD;JLT\n",
+		},
+		{
+			"not and jump",
+			"function Main.f 0\npush local 0\nnot\nif-goto END",
+			"(Main.f)\n@LCL\nA=M\nD=M\nD=D+1\n@Main.f.END\nD;JNE\n",
+		},
+		{
+			"keep the stack in RAM at labels",
+			"push constant 5\nlabel L",
+			"@5\nD=A\n@SP\nAM=M+1\nA=A-1\nM=D\n(.L)\n",
+		},
+	}
+	for _, test := range tests {
+		asm, _ := translateWith(t, "optimized", test.source)
+		if asm != test.expect {
+			t.Errorf("%s: expect\n%sgot\n%s", test.name, test.expect, asm)
+		}
+	}
+}
+
+func TestOptimizingWriterSize(t *testing.T) {
+	source := strings.Join([]string{
+		"function Main.loop 2",
+		"push constant 0",
+		"pop local 0",
+		"label LOOP",
+		"push local 0",
+		"push argument 0",
+		"lt",
+		"not",
+		"if-goto END",
+		"push local 1",
+		"push local 0",
+		"add",
+		"pop local 1",
+		"push local 0",
+		"push constant 1",
+		"add",
+		"pop local 0",
+		"goto LOOP",
+		"label END",
+		"push local 1",
+		"return",
+	}, "\n")
+	_, reference := translateWith(t, "reference", source)
+	_, optimized := translateWith(t, "optimized", source)
+	if optimized*3 > reference*2 {
+		t.Errorf("optimized code has %d instructions, expect less than 2/3 of the %d of the reference", optimized, reference)
+	}
+}
+
+func TestSharedCallsSize(t *testing.T) {
+	lines := []string{"function Main.main 0"}
+	for i := 0; i < 10; i++ {
+		lines = append(lines, "push constant 1", "push constant 2", "call Main.add 2", "pop temp 0")
+	}
+	lines = append(lines, "push constant 0", "return", "function Main.add 0", "push argument 0", "push argument 1", "add", "return")
+	source := strings.Join(lines, "\n")
+	for backend := range backends {
+		_, inline := translateShared(t, backend, false, source)
+		_, shared := translateShared(t, backend, true, source)
+		if shared*3 > inline*2 {
+			t.Errorf("%s: %d instructions with shared calls, expect less than 2/3 of the %d without", backend, shared, inline)
+		}
+	}
+}
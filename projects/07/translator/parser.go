@@ -3,21 +3,27 @@ package main
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
-	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
+
+	"nand2tetris/06/assembler/hackasm"
 )
 
 type Parser struct {
 	reader     *bufio.Reader
+	filename   string
 	curLine    string
+	lineNo     int
 	curCommand Command
 }
 
-func NewParser(reader io.Reader) *Parser {
+func NewParser(filename string, reader io.Reader) *Parser {
 	return &Parser{
-		reader: bufio.NewReader(reader),
+		reader:   bufio.NewReader(reader),
+		filename: filename,
 	}
 }
 
@@ -40,51 +46,88 @@ type Command struct {
 	Arg2        int64
 }
 
+var arithmeticCommands = map[string]bool{
+	"add": true, "sub": true, "neg": true,
+	"eq": true, "gt": true, "lt": true,
+	"and": true, "or": true, "not": true,
+}
+
+// segmentSizes 各内存段的有效下标个数
+var segmentSizes = map[string]int64{
+	"argument": 32768,
+	"local":    32768,
+	"static":   32768,
+	"constant": 32768,
+	"this":     32768,
+	"that":     32768,
+	"pointer":  2,
+	"temp":     8,
+}
+
+type token struct {
+	text string
+	col  int
+}
+
 func (p *Parser) HasMoreCommands() bool {
-	line, _, err := p.reader.ReadLine()
+	line, err := p.reader.ReadString('\n')
 	if err != nil {
-		if errors.Is(err, io.EOF) {
+		if !errors.Is(err, io.EOF) {
+			panic(err)
+		}
+		if len(line) == 0 {
 			return false
 		}
-		panic(err)
 	}
-	p.curLine = string(line)
+	p.curLine = strings.TrimRight(line, "\r\n")
+	p.lineNo += 1
 	return true
 }
 
-func (p *Parser) Advance() {
-	line := strings.TrimSpace(p.curLine)
+// LineNumber 当前命令所在的行，从1开始
+func (p *Parser) LineNumber() int {
+	return p.lineNo
+}
+
+// Advance 解析当前行。有语法错误时命令当作C_COMMENT，
+// 调用者可以继续解析并报告文件中的全部错误
+func (p *Parser) Advance() *hackasm.AsmError {
+	p.curCommand = Command{commandType: C_COMMENT}
+	tokens := splitTokens(p.curLine)
+	if len(tokens) == 0 {
+		return nil
+	}
 
 	var cmd Command
-	if len(line) == 0 {
-		cmd = Command{
-			commandType: C_COMMENT,
-		}
-	} else {
-		re := regexp.MustCompile(`\s+`)
-		tokens := re.Split(line, -1)
-		switch tokens[0] {
-		case "add", "sub", "neg", "eq", "gt", "lt", "and", "or", "not":
-			cmd = parseArithmeticCommand(tokens)
-		case "push":
-			cmd = parsePushCommand(tokens)
-		case "pop":
-			cmd = parsePopCommand(tokens)
-		case "call":
-			cmd = parseCallCommand(tokens)
-		case "function":
-			cmd = parseFunctionCommand(tokens)
-		case "return":
-			cmd = parseRetrunCommand(tokens)
-		case "if-goto":
-			cmd = parseIfCommand(tokens)
-		case "goto":
-			cmd = parseGotoCommand(tokens)
-		case "label":
-			cmd = parseLabelCommand(tokens)
-		}
+	var err *hackasm.AsmError
+	op := tokens[0]
+	switch {
+	case arithmeticCommands[op.text]:
+		cmd, err = p.parseArithmeticCommand(tokens)
+	case op.text == "push":
+		cmd, err = p.parsePushPopCommand(C_PUSH, tokens)
+	case op.text == "pop":
+		cmd, err = p.parsePushPopCommand(C_POP, tokens)
+	case op.text == "call":
+		cmd, err = p.parseCallableCommand(C_CALL, tokens)
+	case op.text == "function":
+		cmd, err = p.parseCallableCommand(C_FUNCTION, tokens)
+	case op.text == "return":
+		cmd, err = p.parseRetrunCommand(tokens)
+	case op.text == "if-goto":
+		cmd, err = p.parseLabelCommand(C_IF, tokens)
+	case op.text == "goto":
+		cmd, err = p.parseLabelCommand(C_GOTO, tokens)
+	case op.text == "label":
+		cmd, err = p.parseLabelCommand(C_LABEL, tokens)
+	default:
+		err = p.errorAt(op, "unknown command")
+	}
+	if err != nil {
+		return err
 	}
 	p.curCommand = cmd
+	return nil
 }
 
 func (p *Parser) CommandType() int {
@@ -99,87 +142,152 @@ func (p *Parser) Arg2() int64 {
 	return p.curCommand.Arg2
 }
 
-func parseArithmeticCommand(tokens []string) Command {
-	if len(tokens) != 1 {
+// splitTokens 按空白把一行切分为单词，去掉//注释
+func splitTokens(line string) []token {
+	if index := strings.Index(line, "//"); index != -1 {
+		line = line[:index]
+	}
+	var tokens []token
+	start := -1
+	for i, c := range line + " " {
+		if unicode.IsSpace(c) {
+			if start != -1 {
+				tokens = append(tokens, token{text: line[start:i], col: start + 1})
+				start = -1
+			}
+		} else if start == -1 {
+			start = i
+		}
+	}
+	return tokens
+}
 
+func (p *Parser) errorAt(t token, format string, args ...interface{}) *hackasm.AsmError {
+	return &hackasm.AsmError{
+		File: p.filename,
+		Line: p.lineNo,
+		Col:  t.col,
+		Text: t.text,
+		Msg:  fmt.Sprintf(format, args...),
 	}
-	return Command{
-		commandType: C_ARITHMETIC,
-		Arg1:        tokens[0],
+}
+
+// checkArity 检查命令tokens[0]是否缺少或者多出参数
+func (p *Parser) checkArity(tokens []token, args ...string) *hackasm.AsmError {
+	op := tokens[0]
+	if len(tokens)-1 < len(args) {
+		return p.errorAt(op, "missing %s, expect '%s %s'", args[len(tokens)-1], op.text, strings.Join(args, " "))
+	}
+	if len(tokens)-1 > len(args) {
+		if len(args) == 0 {
+			return p.errorAt(tokens[len(args)+1], "'%s' takes no arguments", op.text)
+		}
+		return p.errorAt(tokens[len(args)+1], "unexpected argument, expect '%s %s'", op.text, strings.Join(args, " "))
 	}
+	return nil
 }
 
-func parsePopCommand(tokens []string) Command {
-	arg2, err := strconv.ParseInt(tokens[2], 10, 64)
-	if err != nil {
-		panic(err)
+// isIdentifier name是否是合法的VM标签或函数名：
+// 由字母、数字、'_'、'.'和':'组成，不以数字开头
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if unicode.IsDigit(c) {
+			if i == 0 {
+				return false
+			}
+			continue
+		}
+		if c > unicode.MaxASCII || !unicode.IsLetter(c) && !strings.ContainsRune("_.:", c) {
+			return false
+		}
 	}
-	return Command{
-		commandType: C_POP,
-		Arg1:        tokens[1],
-		Arg2:        arg2,
+	return len(name) > 0
+}
+
+func (p *Parser) parseIndex(t token, limit int64) (int64, *hackasm.AsmError) {
+	index, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil || index < 0 || t.text[0] == '+' {
+		return 0, p.errorAt(t, "expect a non-negative integer")
+	}
+	if index >= limit {
+		return 0, p.errorAt(t, "out of range, expect 0..%d", limit-1)
 	}
+	return index, nil
 }
 
-func parsePushCommand(tokens []string) Command {
-	arg2, err := strconv.ParseInt(tokens[2], 10, 64)
-	if err != nil {
-		panic(err)
+func (p *Parser) parseArithmeticCommand(tokens []token) (Command, *hackasm.AsmError) {
+	if err := p.checkArity(tokens); err != nil {
+		return Command{}, err
 	}
 	return Command{
-		commandType: C_PUSH,
-		Arg1:        tokens[1],
-		Arg2:        arg2,
-	}
+		commandType: C_ARITHMETIC,
+		Arg1:        tokens[0].text,
+	}, nil
 }
 
-func parseCallCommand(tokens []string) Command {
-	arg2, err := strconv.ParseInt(tokens[2], 10, 64)
+func (p *Parser) parsePushPopCommand(commandType int, tokens []token) (Command, *hackasm.AsmError) {
+	if err := p.checkArity(tokens, "segment", "index"); err != nil {
+		return Command{}, err
+	}
+	segment := tokens[1]
+	size, ok := segmentSizes[segment.text]
+	if !ok {
+		return Command{}, p.errorAt(segment, "unknown segment")
+	}
+	if commandType == C_POP && segment.text == "constant" {
+		return Command{}, p.errorAt(segment, "cannot pop to the constant segment")
+	}
+	index, err := p.parseIndex(tokens[2], size)
 	if err != nil {
-		panic(err)
+		return Command{}, err
 	}
 	return Command{
-		commandType: C_CALL,
-		Arg1:        tokens[1],
-		Arg2:        arg2,
-	}
+		commandType: commandType,
+		Arg1:        segment.text,
+		Arg2:        index,
+	}, nil
 }
 
-func parseFunctionCommand(tokens []string) Command {
-	arg2, err := strconv.ParseInt(tokens[2], 10, 64)
+func (p *Parser) parseCallableCommand(commandType int, tokens []token) (Command, *hackasm.AsmError) {
+	count := "nVars"
+	if commandType == C_CALL {
+		count = "nArgs"
+	}
+	if err := p.checkArity(tokens, "name", count); err != nil {
+		return Command{}, err
+	}
+	if !isIdentifier(tokens[1].text) {
+		return Command{}, p.errorAt(tokens[1], "invalid function name")
+	}
+	n, err := p.parseIndex(tokens[2], 32768)
 	if err != nil {
-		panic(err)
+		return Command{}, err
 	}
 	return Command{
-		commandType: C_FUNCTION,
-		Arg1:        tokens[1],
-		Arg2:        arg2,
-	}
+		commandType: commandType,
+		Arg1:        tokens[1].text,
+		Arg2:        n,
+	}, nil
 }
 
-func parseRetrunCommand(tokens []string) Command {
+func (p *Parser) parseRetrunCommand(tokens []token) (Command, *hackasm.AsmError) {
+	if err := p.checkArity(tokens); err != nil {
+		return Command{}, err
+	}
 	return Command{
 		commandType: C_RETURN,
-	}
+	}, nil
 }
 
-func parseIfCommand(tokens []string) Command {
-	return Command{
-		commandType: C_IF,
-		Arg1:        tokens[1],
+func (p *Parser) parseLabelCommand(commandType int, tokens []token) (Command, *hackasm.AsmError) {
+	if err := p.checkArity(tokens, "label"); err != nil {
+		return Command{}, err
 	}
-}
-
-func parseGotoCommand(tokens []string) Command {
-	return Command{
-		commandType: C_GOTO,
-		Arg1:        tokens[1],
+	if !isIdentifier(tokens[1].text) {
+		return Command{}, p.errorAt(tokens[1], "invalid label")
 	}
-}
-
-func parseLabelCommand(tokens []string) Command {
 	return Command{
-		commandType: C_LABEL,
-		Arg1:        tokens[1],
-	}
+		commandType: commandType,
+		Arg1:        tokens[1].text,
+	}, nil
 }
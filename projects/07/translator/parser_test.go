@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	source := strings.Join([]string{
+		"// comment",
+		"",
+		"push constant 7   // trailing comment",
+		"  pop   temp\t7",
+		"add",
+		"label LOOP_1",
+		"if-goto Main.loop:2",
+		"function Main.main 2",
+		"call Math.multiply 2",
+		"return",
+	}, "\n")
+	expect := []Command{
+		{commandType: C_COMMENT},
+		{commandType: C_COMMENT},
+		{C_PUSH, "constant", 7},
+		{C_POP, "temp", 7},
+		{C_ARITHMETIC, "add", 0},
+		{C_LABEL, "LOOP_1", 0},
+		{C_IF, "Main.loop:2", 0},
+		{C_FUNCTION, "Main.main", 2},
+		{C_CALL, "Math.multiply", 2},
+		{commandType: C_RETURN},
+	}
+	parser := NewParser("Test.vm", strings.NewReader(source))
+	for i, cmd := range expect {
+		if !parser.HasMoreCommands() {
+			t.Fatalf("expect %d commands, got %d", len(expect), i)
+		}
+		if err := parser.Advance(); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if parser.curCommand != cmd {
+			t.Errorf("line %d: expect %+v, got %+v", i+1, cmd, parser.curCommand)
+		}
+	}
+	if parser.HasMoreCommands() {
+		t.Errorf("expect end of file")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		msg  string
+	}{
+		{"pop temp 9", 10, "out of range"},
+		{"push pointer 2", 14, "out of range"},
+		{"pop constant 3", 5, "cannot pop"},
+		{"push local -1", 12, "non-negative"},
+		{"push local", 1, "missing index"},
+		{"push heap 0", 6, "unknown segment"},
+		{"neg 1", 5, "takes no arguments"},
+		{"return 0", 8, "takes no arguments"},
+		{"goto", 1, "missing label"},
+		{"label 9lives", 7, "invalid label"},
+		{"label a-b", 7, "invalid label"},
+		{"function Main.main", 1, "missing nVars"},
+		{"call Main.main 1 2", 18, "unexpected argument"},
+		{"call 1Main 0", 6, "invalid function name"},
+		{"pusj constant 1", 1, "unknown command"},
+	}
+	for _, test := range tests {
+		parser := NewParser("Test.vm", strings.NewReader(test.line))
+		parser.HasMoreCommands()
+		err := parser.Advance()
+		if err == nil {
+			t.Errorf("%s: expect an error", test.line)
+			continue
+		}
+		if err.Line != 1 || err.Col != test.col || !strings.Contains(err.Msg, test.msg) {
+			t.Errorf("%s: expect '%s' at column %d, got %v", test.line, test.msg, test.col, err)
+		}
+		if parser.CommandType() != C_COMMENT {
+			t.Errorf("%s: expect the command to be skipped", test.line)
+		}
+	}
+}
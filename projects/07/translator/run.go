@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// run 在VM中运行path，启动方式与翻译后的程序相同：
+// SP=256，定义了Sys.init时调用Sys.init
+func run(path string) error {
+	files, err := vmFiles(path)
+	if err != nil {
+		return err
+	}
+	vm := NewVM()
+	vm.OSDir = findOSDir(path)
+	if err := vm.Load(files...); err != nil {
+		return err
+	}
+	vm.Bootstrap()
+	if err := vm.Run(*maxSteps); err != nil {
+		return err
+	}
+	state := "step limit reached"
+	if vm.Halted() {
+		state = "halted"
+	}
+	fmt.Printf("// stopped: %s, steps: %d, function: %s\n", state, vm.Steps, vm.CurrentFunction())
+	if *dumpRanges == "" {
+		return nil
+	}
+	for _, r := range strings.Split(*dumpRanges, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(r), "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		to := from
+		if err == nil && len(bounds) == 2 {
+			to, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || from < 0 || to < from || to >= vmRAMSize {
+			return fmt.Errorf("invalid RAM range '%s'", r)
+		}
+		for i := from; i <= to; i++ {
+			fmt.Printf("RAM[%d] = %d\n", i, int16(vm.RAM[i]))
+		}
+	}
+	return nil
+}
+
+// diff 比较path在VM中运行和翻译为Hack后运行的结果，返回内存是否相同
+func diff(path string) (bool, error) {
+	files, err := vmFiles(path)
+	if err != nil {
+		return false, err
+	}
+	differ, err := NewDiffer(DiffOptions{Backend: *backendName, SharedCalls: *sharedCalls, OSDir: findOSDir(path)}, files...)
+	if err != nil {
+		return false, err
+	}
+	result, err := differ.Run(*maxSteps)
+	if err != nil {
+		return false, err
+	}
+	result.Write(os.Stdout)
+	return len(result.Mismatches) == 0, nil
+}
+
+// findOSDir 返回-os，没有指定时在path的上级目录中查找tools/OS
+func findOSDir(path string) string {
+	if *osDir != "" {
+		return *osDir
+	}
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, "tools", "OS")
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
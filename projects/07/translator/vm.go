@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"nand2tetris/06/assembler/hackasm"
+)
+
+const (
+	vmRAMSize   = 32768
+	vmStackBase = 256
+	vmStatic    = 16
+	vmTemp      = 5
+)
+
+// VM的寄存器，地址与CodeWriter使用的符号相同
+const (
+	SP   = 0
+	LCL  = 1
+	ARG  = 2
+	THIS = 3
+	THAT = 4
+)
+
+// vmCommand 解析得到的命令，以及它在源码中的位置和解析后的跳转目标
+type vmCommand struct {
+	Command
+	file     string
+	line     int
+	class    string
+	function string
+	target   int
+}
+
+// VM 直接执行.vm命令，而不是翻译为Hack汇编。
+// RAM的布局与CodeWriter相同，以便比较两者：SP、LCL、ARG、THIS、THAT
+// 在RAM[0..4]，temp在RAM[5..12]，静态变量从RAM[16]开始按首次使用的顺序分配，
+// 与汇编器分配变量的方式相同，栈从RAM[256]开始。
+// 返回地址是call之后的命令的下标
+type VM struct {
+	RAM [vmRAMSize]uint16
+	// PC 下一条命令的下标
+	PC int
+	// Steps 自上次Reset以来执行的命令数
+	Steps int64
+	// OSDir Jack OS各个类的.vm文件所在的目录，
+	// 调用程序中没有定义的函数时从这里加载
+	OSDir string
+
+	commands   []vmCommand
+	functions  map[string]int
+	statics    map[string]uint16
+	nextStatic uint16
+	classes    map[string]bool
+	halted     bool
+}
+
+func NewVM() *VM {
+	return &VM{}
+}
+
+// vmFiles 列出path（文件或目录）中的.vm文件，
+// 顺序与翻译器写入的顺序相同
+func vmFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".vm") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Load 用paths中的.vm文件替换程序并重置VM。
+// 设置了OSDir时，未定义的函数从OSDir加载
+func (vm *VM) Load(paths ...string) error {
+	vm.commands = nil
+	vm.functions = map[string]int{}
+	vm.statics = map[string]uint16{}
+	vm.nextStatic = vmStatic
+	vm.classes = map[string]bool{}
+
+	var errs hackasm.ErrorList
+	if err := vm.loadFiles(paths, &errs); err != nil {
+		return err
+	}
+	if vm.OSDir != "" && len(vm.undefinedCalls()) > 0 {
+		osFiles, err := filepath.Glob(filepath.Join(vm.OSDir, "*.vm"))
+		if err != nil {
+			return err
+		}
+		var needed []string
+		for _, path := range osFiles {
+			if !vm.classes[className(path)] {
+				needed = append(needed, path)
+			}
+		}
+		if err := vm.loadFiles(needed, &errs); err != nil {
+			return err
+		}
+	}
+	for _, i := range vm.undefinedCalls() {
+		cmd := vm.commands[i]
+		errs = append(errs, &hackasm.AsmError{File: cmd.file, Line: cmd.line, Col: 1, Text: cmd.Arg1, Msg: "undefined function"})
+	}
+	vm.resolveLabels(&errs)
+	if err := errs.Err(); err != nil {
+		return err
+	}
+	vm.Reset()
+	return nil
+}
+
+func className(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".vm")
+}
+
+func (vm *VM) loadFiles(paths []string, errs *hackasm.ErrorList) error {
+	function := ""
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		class := className(path)
+		vm.classes[class] = true
+		parser := NewParser(path, f)
+		for parser.HasMoreCommands() {
+			if err := parser.Advance(); err != nil {
+				*errs = append(*errs, err)
+				continue
+			}
+			if parser.CommandType() == C_COMMENT {
+				continue
+			}
+			cmd := vmCommand{
+				Command:  parser.curCommand,
+				file:     path,
+				line:     parser.LineNumber(),
+				class:    class,
+				function: function,
+			}
+			switch cmd.commandType {
+			case C_FUNCTION:
+				if _, ok := vm.functions[cmd.Arg1]; ok {
+					*errs = append(*errs, &hackasm.AsmError{File: path, Line: cmd.line, Col: 1, Text: cmd.Arg1, Msg: "duplicate function"})
+				}
+				vm.functions[cmd.Arg1] = len(vm.commands)
+				function = cmd.Arg1
+				cmd.function = function
+			case C_PUSH, C_POP:
+				if cmd.Arg1 == "static" {
+					key := fmt.Sprintf("%s.%d", class, cmd.Arg2)
+					if _, ok := vm.statics[key]; !ok {
+						vm.statics[key] = vm.nextStatic
+						vm.nextStatic += 1
+					}
+				}
+			}
+			vm.commands = append(vm.commands, cmd)
+		}
+		f.Close()
+	}
+	return nil
+}
+
+// undefinedCalls 调用未加载的函数的call命令的下标
+func (vm *VM) undefinedCalls() []int {
+	var calls []int
+	for i, cmd := range vm.commands {
+		if cmd.commandType == C_CALL {
+			if _, ok := vm.functions[cmd.Arg1]; !ok {
+				calls = append(calls, i)
+			}
+		}
+	}
+	return calls
+}
+
+// resolveLabels 设置goto、if-goto和call的目标。
+// 与CodeWriter相同，标签的作用域是所在的函数
+func (vm *VM) resolveLabels(errs *hackasm.ErrorList) {
+	labels := map[string]int{}
+	for i, cmd := range vm.commands {
+		if cmd.commandType != C_LABEL {
+			continue
+		}
+		key := cmd.function + "." + cmd.Arg1
+		if _, ok := labels[key]; ok {
+			*errs = append(*errs, &hackasm.AsmError{File: cmd.file, Line: cmd.line, Col: 1, Text: cmd.Arg1, Msg: "duplicate label"})
+		}
+		labels[key] = i
+	}
+	for i := range vm.commands {
+		cmd := &vm.commands[i]
+		switch cmd.commandType {
+		case C_GOTO, C_IF:
+			target, ok := labels[cmd.function+"."+cmd.Arg1]
+			if !ok {
+				*errs = append(*errs, &hackasm.AsmError{File: cmd.file, Line: cmd.line, Col: 1, Text: cmd.Arg1, Msg: "undefined label"})
+			}
+			cmd.target = target
+		case C_CALL:
+			cmd.target = vm.functions[cmd.Arg1]
+		}
+	}
+}
+
+// Reset 定义了Sys.init时从Sys.init重新开始，否则从第一条命令开始，
+// RAM保持不变
+func (vm *VM) Reset() {
+	vm.PC = 0
+	if entry, ok := vm.functions["Sys.init"]; ok {
+		vm.PC = entry
+	}
+	vm.Steps = 0
+	vm.halted = false
+}
+
+// Bootstrap 与CodeWriter.WriteInit生成的代码相同：SP=256并调用Sys.init。
+// 没有Sys.init时只设置SP
+func (vm *VM) Bootstrap() {
+	vm.Reset()
+	vm.RAM[SP] = vmStackBase
+	if entry, ok := vm.functions["Sys.init"]; ok {
+		vm.call(entry, 0, len(vm.commands))
+	}
+}
+
+// Halted 程序是否执行完最后一条命令、从引导代码的call返回、
+// 进入了Sys.halt或者停在跳回自身的goto上
+func (vm *VM) Halted() bool {
+	return vm.halted
+}
+
+// CurrentFunction 下一条命令所在的函数
+func (vm *VM) CurrentFunction() string {
+	if vm.PC < 0 || vm.PC >= len(vm.commands) {
+		return ""
+	}
+	return vm.commands[vm.PC].function
+}
+
+func (vm *VM) push(value uint16) error {
+	sp := vm.RAM[SP]
+	if int(sp) >= vmRAMSize {
+		return fmt.Errorf("stack overflow, SP=%d", sp)
+	}
+	vm.RAM[sp] = value
+	vm.RAM[SP] = sp + 1
+	return nil
+}
+
+func (vm *VM) pop() (uint16, error) {
+	sp := vm.RAM[SP]
+	if sp == 0 || int(sp) > vmRAMSize {
+		return 0, fmt.Errorf("stack underflow, SP=%d", sp)
+	}
+	vm.RAM[SP] = sp - 1
+	return vm.RAM[sp-1], nil
+}
+
+// segmentAddress segment[index]的RAM地址
+func (vm *VM) segmentAddress(cmd *vmCommand) (int, error) {
+	index := int(cmd.Arg2)
+	var address int
+	switch cmd.Arg1 {
+	case "local":
+		address = int(vm.RAM[LCL]) + index
+	case "argument":
+		address = int(vm.RAM[ARG]) + index
+	case "this":
+		address = int(vm.RAM[THIS]) + index
+	case "that":
+		address = int(vm.RAM[THAT]) + index
+	case "pointer":
+		address = THIS + index
+	case "temp":
+		address = vmTemp + index
+	case "static":
+		address = int(vm.statics[fmt.Sprintf("%s.%d", cmd.class, cmd.Arg2)])
+	default:
+		return 0, fmt.Errorf("no address for segment '%s'", cmd.Arg1)
+	}
+	if address >= vmRAMSize {
+		return 0, fmt.Errorf("%s %d is RAM[%d], out of range", cmd.Arg1, index, address)
+	}
+	return address, nil
+}
+
+func (vm *VM) call(entry int, nArgs int64, ret int) error {
+	for _, value := range []uint16{uint16(ret), vm.RAM[LCL], vm.RAM[ARG], vm.RAM[THIS], vm.RAM[THAT]} {
+		if err := vm.push(value); err != nil {
+			return err
+		}
+	}
+	vm.RAM[ARG] = vm.RAM[SP] - uint16(nArgs) - 5
+	vm.RAM[LCL] = vm.RAM[SP]
+	vm.PC = entry
+	return nil
+}
+
+func (vm *VM) doReturn() error {
+	frame := vm.RAM[LCL]
+	if frame < 5 {
+		return fmt.Errorf("return without a frame, LCL=%d", frame)
+	}
+	if int(frame) > vmRAMSize {
+		return fmt.Errorf("return frame out of range, LCL=%d", frame)
+	}
+	if int(vm.RAM[ARG]) >= vmRAMSize {
+		return fmt.Errorf("return value out of range, ARG=%d", vm.RAM[ARG])
+	}
+	ret := vm.RAM[frame-5]
+	value, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	vm.RAM[vm.RAM[ARG]] = value
+	vm.RAM[SP] = vm.RAM[ARG] + 1
+	vm.RAM[THAT] = vm.RAM[frame-1]
+	vm.RAM[THIS] = vm.RAM[frame-2]
+	vm.RAM[ARG] = vm.RAM[frame-3]
+	vm.RAM[LCL] = vm.RAM[frame-4]
+	vm.PC = int(ret)
+	return nil
+}
+
+func boolValue(b bool) uint16 {
+	if b {
+		return 0xffff
+	}
+	return 0
+}
+
+func (vm *VM) arithmetic(op string) error {
+	y, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if op == "neg" || op == "not" {
+		if op == "neg" {
+			return vm.push(-y)
+		}
+		return vm.push(^y)
+	}
+	x, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	var result uint16
+	switch op {
+	case "add":
+		result = x + y
+	case "sub":
+		result = x - y
+	case "eq":
+		result = boolValue(x == y)
+	case "gt":
+		result = boolValue(int16(x) > int16(y))
+	case "lt":
+		result = boolValue(int16(x) < int16(y))
+	case "and":
+		result = x & y
+	case "or":
+		result = x | y
+	}
+	return vm.push(result)
+}
+
+// Step 执行一条命令。与课程的VM模拟器相同，标签不算作命令，
+// 跳过标签不计入步数
+func (vm *VM) Step() error {
+	for vm.PC >= 0 && vm.PC < len(vm.commands) && vm.commands[vm.PC].commandType == C_LABEL {
+		vm.PC += 1
+	}
+	if vm.PC < 0 || vm.PC >= len(vm.commands) {
+		vm.halted = true
+		return nil
+	}
+	cmd := &vm.commands[vm.PC]
+	err := vm.exec(cmd)
+	vm.Steps += 1
+	if err != nil {
+		return fmt.Errorf("%s:%d: %v", cmd.file, cmd.line, err)
+	}
+	if vm.PC >= len(vm.commands) || vm.CurrentFunction() == "Sys.halt" {
+		vm.halted = true
+	}
+	return nil
+}
+
+func (vm *VM) exec(cmd *vmCommand) error {
+	pc := vm.PC
+	vm.PC += 1
+	switch cmd.commandType {
+	case C_ARITHMETIC:
+		return vm.arithmetic(cmd.Arg1)
+	case C_PUSH:
+		if cmd.Arg1 == "constant" {
+			return vm.push(uint16(cmd.Arg2))
+		}
+		address, err := vm.segmentAddress(cmd)
+		if err != nil {
+			return err
+		}
+		return vm.push(vm.RAM[address])
+	case C_POP:
+		address, err := vm.segmentAddress(cmd)
+		if err != nil {
+			return err
+		}
+		value, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.RAM[address] = value
+	case C_LABEL:
+	case C_GOTO:
+		vm.jump(pc, cmd.target)
+	case C_IF:
+		value, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if value != 0 {
+			vm.jump(pc, cmd.target)
+		}
+	case C_FUNCTION:
+		for i := int64(0); i < cmd.Arg2; i++ {
+			if err := vm.push(0); err != nil {
+				return err
+			}
+		}
+	case C_CALL:
+		return vm.call(cmd.target, cmd.Arg2, vm.PC)
+	case C_RETURN:
+		return vm.doReturn()
+	}
+	return nil
+}
+
+// jump 跳到target，只跳过标签跳回自身的goto永远不会结束
+func (vm *VM) jump(pc, target int) {
+	vm.PC = target
+	if target > pc || vm.commands[pc].commandType != C_GOTO {
+		return
+	}
+	for i := target; i < pc; i++ {
+		if vm.commands[i].commandType != C_LABEL {
+			return
+		}
+	}
+	vm.halted = true
+}
+
+// Run 执行命令直到程序停止或者执行了maxSteps条（<=0表示不限制）
+func (vm *VM) Run(maxSteps int64) error {
+	for i := int64(0); !vm.halted && (maxSteps <= 0 || i < maxSteps); i++ {
+		if err := vm.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
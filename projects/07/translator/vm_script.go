@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"nand2tetris/06/assembler/tst"
+)
+
+// ScriptRunner 运行07和08中VM模拟器的.tst脚本，
+// 并将输出与对应的.cmp文件比较
+type ScriptRunner struct {
+	// OutputDir 设置时output-file写到这里，否则写在脚本旁边
+	OutputDir string
+	// OSDir 传给VM，用于加载Jack OS的类
+	OSDir string
+	// Echo 接收echo命令的输出，为nil时忽略
+	Echo io.Writer
+
+	dir string
+	vm  *VM
+	out tst.Output
+}
+
+func NewScriptRunner() *ScriptRunner {
+	return &ScriptRunner{}
+}
+
+// VM 最后一个脚本使用的虚拟机，用于事后检查状态
+func (r *ScriptRunner) VM() *VM {
+	return r.vm
+}
+
+// RunFile 运行path处的脚本，脚本中的相对路径相对于脚本所在的目录
+func (r *ScriptRunner) RunFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Run(path, f)
+}
+
+func (r *ScriptRunner) Run(filename string, reader io.Reader) error {
+	statements, err := tst.Parse(filename, reader)
+	if err != nil {
+		return err
+	}
+	r.dir = filepath.Dir(filename)
+	r.vm = NewVM()
+	r.vm.OSDir = r.OSDir
+	r.out.Reset()
+	r.out.Dir = r.OutputDir
+	defer r.out.Close()
+	if err := r.exec(statements); err != nil {
+		return fmt.Errorf("%s:%v", filename, err)
+	}
+	return nil
+}
+
+func (r *ScriptRunner) exec(statements []tst.Statement) error {
+	for _, stmt := range statements {
+		if err := r.execStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ScriptRunner) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(r.dir, name)
+}
+
+func (r *ScriptRunner) execStatement(stmt tst.Statement) error {
+	args := stmt.Args
+	wrap := func(err error) error {
+		if err == nil {
+			return nil
+		}
+		return fmt.Errorf("%d: %v", stmt.Line, err)
+	}
+	expectArgs := func(n int) error {
+		if len(args) != n {
+			return wrap(fmt.Errorf("%s expects %d arguments, got %d", args[0], n-1, len(args)-1))
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "load":
+		// 没有指定文件时加载脚本所在目录的所有.vm文件
+		path := r.dir
+		if len(args) > 1 {
+			path = r.path(args[1])
+		}
+		files, err := vmFiles(path)
+		if err != nil {
+			return wrap(err)
+		}
+		return wrap(r.vm.Load(files...))
+	case "output-file":
+		if err := expectArgs(2); err != nil {
+			return err
+		}
+		return wrap(r.out.Open(r.path(args[1])))
+	case "compare-to":
+		if err := expectArgs(2); err != nil {
+			return err
+		}
+		return wrap(r.out.ReadCompare(r.path(args[1])))
+	case "output-list":
+		return wrap(r.out.SetColumns(args[1:]))
+	case "set":
+		if err := expectArgs(3); err != nil {
+			return err
+		}
+		value, err := tst.ParseValue(args[2])
+		if err != nil {
+			return wrap(err)
+		}
+		cell, err := r.variable(args[1])
+		if err != nil {
+			return wrap(err)
+		}
+		*cell = value
+		return nil
+	case "repeat":
+		if err := expectArgs(2); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return wrap(fmt.Errorf("invalid repeat count '%s'", args[1]))
+		}
+		for i := 0; i < n; i++ {
+			if err := r.exec(stmt.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "vmstep":
+		return wrap(r.vm.Step())
+	case "output":
+		return wrap(r.out.Write(r.columnValue))
+	case "echo":
+		if r.Echo != nil {
+			fmt.Fprintln(r.Echo, strings.Trim(strings.Join(args[1:], " "), `"`))
+		}
+		return nil
+	case "clear-echo":
+		return nil
+	}
+	return wrap(fmt.Errorf("unsupported command '%s'", args[0]))
+}
+
+var vmVariablePattern = regexp.MustCompile(`^([A-Za-z]+)(?:\[(\d+)\])?$`)
+
+// variable 脚本变量对应的RAM单元：sp、local、argument、this、that、
+// RAM[n]，或者local[2]、temp[0]这样的内存段元素
+func (r *ScriptRunner) variable(name string) (*uint16, error) {
+	m := vmVariablePattern.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("unknown variable '%s'", name)
+	}
+	vm := r.vm
+	registers := map[string]int{"sp": SP, "local": LCL, "argument": ARG, "this": THIS, "that": THAT}
+	if m[2] == "" {
+		if register, ok := registers[m[1]]; ok {
+			return &vm.RAM[register], nil
+		}
+		return nil, fmt.Errorf("unknown variable '%s'", name)
+	}
+	index, _ := strconv.Atoi(m[2])
+	var address int
+	switch m[1] {
+	case "RAM":
+		address = index
+	case "local", "argument", "this", "that":
+		address = int(vm.RAM[registers[m[1]]]) + index
+	case "temp":
+		address = vmTemp + index
+	case "pointer":
+		address = THIS + index
+	default:
+		return nil, fmt.Errorf("unknown variable '%s'", name)
+	}
+	if address >= vmRAMSize {
+		return nil, fmt.Errorf("%s is RAM[%d], out of range", name, address)
+	}
+	return &vm.RAM[address], nil
+}
+
+func (r *ScriptRunner) columnValue(column tst.Column) (string, error) {
+	if column.Name == "currentFunction" {
+		return r.vm.CurrentFunction(), nil
+	}
+	cell, err := r.variable(column.Name)
+	if err != nil {
+		return "", err
+	}
+	return column.Format16(*cell), nil
+}
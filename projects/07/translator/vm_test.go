@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadVM(t *testing.T, sources map[string]string) *VM {
+	t.Helper()
+	dir := t.TempDir()
+	var files []string
+	for name, source := range sources {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	vm := NewVM()
+	if err := vm.Load(files...); err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestVMRun(t *testing.T) {
+	vm := loadVM(t, map[string]string{
+		"Sys.vm": strings.Join([]string{
+			"function Sys.init 0",
+			"push constant 5",
+			"call Main.fact 1",
+			"pop static 0",
+			"push constant 3",
+			"push constant 7",
+			"gt",
+			"pop static 1",
+			"push constant 32767",
+			"neg",
+			"push constant 1",
+			"lt",
+			"pop static 2",
+			"label END",
+			"goto END",
+		}, "\n"),
+		"Main.vm": strings.Join([]string{
+			"// fact(n) = n * fact(n-1), with the multiplication as repeated addition",
+			"function Main.fact 1",
+			"push argument 0",
+			"if-goto RECURSE",
+			"push constant 1",
+			"return",
+			"label RECURSE",
+			"push argument 0",
+			"push constant 1",
+			"sub",
+			"call Main.fact 1",
+			"pop local 0",
+			"push constant 0",
+			"label LOOP",
+			"// -(~n+1) is n again, through pointer 0",
+			"push argument 0",
+			"not",
+			"push constant 1",
+			"add",
+			"neg",
+			"pop pointer 0",
+			"push pointer 0",
+			"push constant 0",
+			"eq",
+			"if-goto DONE",
+			"push local 0",
+			"add",
+			"push argument 0",
+			"push constant 1",
+			"sub",
+			"pop argument 0",
+			"goto LOOP",
+			"label DONE",
+			"return",
+		}, "\n"),
+	})
+	vm.Bootstrap()
+	if err := vm.Run(100000); err != nil {
+		t.Fatal(err)
+	}
+	if !vm.Halted() {
+		t.Fatalf("not halted after %d steps", vm.Steps)
+	}
+	if vm.CurrentFunction() != "Sys.init" {
+		t.Errorf("expect to halt in Sys.init, got %s", vm.CurrentFunction())
+	}
+	// Main.vm没有静态变量，所以Sys.vm的静态变量从16开始
+	for i, expect := range []int16{120, 0, -1} {
+		if got := int16(vm.RAM[vmStatic+i]); got != expect {
+			t.Errorf("static %d = %d, expect %d", i, got, expect)
+		}
+	}
+	if vm.RAM[SP] != 261 {
+		t.Errorf("SP = %d, expect 261", vm.RAM[SP])
+	}
+}
+
+func TestVMLoadErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		msg    string
+	}{
+		{"function Main.main 0\ncall Main.missing 0\nreturn", "Main.missing"},
+		{"function Main.main 0\ngoto NOWHERE\nreturn", "NOWHERE"},
+		{"function Main.main 0\nreturn\nfunction Main.main 0\nreturn", "Main.main"},
+		{"function Main.main 0\nlabel L\nlabel L\nreturn", "L"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "Main.vm")
+		if err := os.WriteFile(path, []byte(test.source), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := NewVM().Load(path)
+		if err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%q: expect an error about %s, got %v", test.source, test.msg, err)
+		}
+	}
+}
+
+// TestVMCorruptedFrame 通过this 0改写LCL或ARG之后再return
+func TestVMCorruptedFrame(t *testing.T) {
+	for _, test := range []struct {
+		pointer string
+		msg     string
+	}{
+		{"1", "LCL=40000"},
+		{"2", "ARG=40000"},
+	} {
+		vm := loadVM(t, map[string]string{
+			"Sys.vm": strings.Join([]string{
+				"function Sys.init 0",
+				"call Main.main 0",
+				"label END",
+				"goto END",
+			}, "\n"),
+			"Main.vm": strings.Join([]string{
+				"function Main.main 0",
+				"push constant " + test.pointer,
+				"pop pointer 0",
+				"push constant 20000",
+				"push constant 20000",
+				"add",
+				"pop this 0",
+				"push constant 0",
+				"return",
+			}, "\n"),
+		})
+		vm.Bootstrap()
+		err := vm.Run(1000)
+		if err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("pointer %s: expect an error about %s, got %v", test.pointer, test.msg, err)
+		}
+	}
+}
+
+// TestVMEScripts 运行07和08中VM模拟器的测试脚本
+func TestVMEScripts(t *testing.T) {
+	scripts, err := filepath.Glob("../../0[78]/*/*/*VME.tst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Skip("no VME scripts found")
+	}
+	for _, script := range scripts {
+		script := script
+		t.Run(strings.TrimSuffix(filepath.Base(script), ".tst"), func(t *testing.T) {
+			runner := NewScriptRunner()
+			runner.OutputDir = t.TempDir()
+			if err := runner.RunFile(script); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}